@@ -0,0 +1,150 @@
+package chaincode
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Access control. A client's role comes from the billing.role attribute its organisation's CA put in
+// its certificate, and is only honoured from the organisation that role belongs to, so no organisation
+// can grant itself another's role:
+//   - fleet operators, from the fleet operator's organisation, record journeys, look after cars and
+//     swap components, and acknowledge or dispute bills;
+//   - suppliers, from the organisation recorded on their Supplier, manage their own fuel cells and
+//     tariffs and settle their own bills; billing.supplier_id names the supplier a client acts for,
+//     as one organisation may run several suppliers;
+//   - billing admins, from the billing organisation, register suppliers and generate bills.
+// Bills can be read by fleet operators and billing admins, and by the supplier they are addressed to.
+
+// Roles granted by the billing.role certificate attribute
+const (
+	RoleFleetOperator = "fleet_operator"
+	RoleSupplier      = "supplier"
+	RoleBillingAdmin  = "billing_admin"
+)
+
+// certificate attributes read by the contract
+const (
+	roleAttribute     = "billing.role"
+	supplierAttribute = "billing.supplier_id"
+)
+
+// fleetMSPID is the organisation operating the cars, which receives and pays bills
+const fleetMSPID = "Org1MSP"
+
+// billingMSPID is the organisation running billing for the fleet
+const billingMSPID = "Org1MSP"
+
+// assertFleetOperator checks the submitting client is a fleet operator
+func assertFleetOperator(ctx contractapi.TransactionContextInterface) error {
+	return assertRole(ctx, fleetMSPID, RoleFleetOperator)
+}
+
+// assertBillingAdmin checks the submitting client is a billing admin
+func assertBillingAdmin(ctx contractapi.TransactionContextInterface) error {
+	return assertRole(ctx, billingMSPID, RoleBillingAdmin)
+}
+
+// assertSupplierClient checks the submitting client acts for the supplier, from the organisation recorded for it
+func (s *SmartContract) assertSupplierClient(ctx contractapi.TransactionContextInterface, supplierID string) error {
+	supplier, err := s.ReadSupplier(ctx, supplierID)
+	if err != nil {
+		return err
+	}
+	if supplier.MSP_ID == "" {
+		return fmt.Errorf("the Supplier %s has no organisation recorded", supplierID)
+	}
+	err = assertRole(ctx, supplier.MSP_ID, RoleSupplier)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetClientIdentity().AssertAttributeValue(supplierAttribute, supplierID)
+	if err != nil {
+		return fmt.Errorf("submitting client not authorized, does not act for Supplier %s", supplierID)
+	}
+	return nil
+}
+
+// assertFuelcellSupplier checks the submitting client acts for the supplier of the fuel cell
+func (s *SmartContract) assertFuelcellSupplier(ctx contractapi.TransactionContextInterface, fuelcellID string) error {
+	fuelcell, err := s.ReadFuelcell(ctx, fuelcellID)
+	if err != nil {
+		return err
+	}
+	return s.assertSupplierClient(ctx, fuelcell.Supplier_ID)
+}
+
+// assertBillReader checks the submitting client may read bills addressed to the supplier
+func (s *SmartContract) assertBillReader(ctx contractapi.TransactionContextInterface, supplierID string) error {
+	scope, err := s.billReaderScope(ctx)
+	if err != nil {
+		return err
+	}
+	if scope != "" && scope != supplierID {
+		return fmt.Errorf("submitting client not authorized, bills for Supplier %s are not addressed to Supplier %s", supplierID, scope)
+	}
+	return nil
+}
+
+// billQuery returns the selector for the bills the submitting client may read
+func (s *SmartContract) billQuery(ctx contractapi.TransactionContextInterface) (selector, error) {
+	scope, err := s.billReaderScope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if scope != "" {
+		return selector{"AssetType": "Bill", "Supplier_ID": scope}, nil
+	}
+	return selector{"AssetType": "Bill"}, nil
+}
+
+// billReaderScope returns the supplier whose bills the submitting client may read, or an empty string
+// if it may read every bill
+func (s *SmartContract) billReaderScope(ctx contractapi.TransactionContextInterface) (string, error) {
+	role, _, err := ctx.GetClientIdentity().GetAttributeValue(roleAttribute)
+	if err != nil {
+		return "", fmt.Errorf("failed to get the %s attribute: %v", roleAttribute, err)
+	}
+	switch role {
+	case RoleFleetOperator:
+		return "", assertFleetOperator(ctx)
+	case RoleBillingAdmin:
+		return "", assertBillingAdmin(ctx)
+	case RoleSupplier:
+		supplierID, found, err := ctx.GetClientIdentity().GetAttributeValue(supplierAttribute)
+		if err != nil {
+			return "", fmt.Errorf("failed to get the %s attribute: %v", supplierAttribute, err)
+		}
+		if !found || supplierID == "" {
+			return "", fmt.Errorf("submitting client not authorized, does not have the %s attribute", supplierAttribute)
+		}
+		return supplierID, s.assertSupplierClient(ctx, supplierID)
+	}
+	return "", fmt.Errorf("submitting client not authorized to read bills, does not have a billing role")
+}
+
+// assertRole checks the submitting client belongs to the organisation and has the role in its certificate
+func assertRole(ctx contractapi.TransactionContextInterface, mspID string, role string) error {
+	err := assertClientMSP(ctx, mspID)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetClientIdentity().AssertAttributeValue(roleAttribute, role)
+	if err != nil {
+		return fmt.Errorf("submitting client not authorized, does not have the %s role", role)
+	}
+	return nil
+}
+
+// assertClientMSP checks the submitting client belongs to the given organisation
+func assertClientMSP(ctx contractapi.TransactionContextInterface, mspID string) error {
+	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get MSPID: %v", err)
+	}
+	if clientMSPID != mspID {
+		return fmt.Errorf("client from org %s is not authorized, only %s may do this", clientMSPID, mspID)
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode/mocks"
+	"github.com/stretchr/testify/require"
+)
+
+//go:generate counterfeiter -o mocks/clientIdentity.go -fake-name ClientIdentity . clientIdentity
+type clientIdentity interface {
+	cid.ClientIdentity
+}
+
+// clientWith returns a transaction context for a client of the organisation holding the certificate
+// attributes, whose world state holds the given assets under their keys
+func clientWith(mspID string, attributes map[string]string, assets map[string]interface{}) (*mocks.TransactionContext, error) {
+	identity := &mocks.ClientIdentity{}
+	identity.GetMSPIDReturns(mspID, nil)
+	identity.GetAttributeValueStub = func(name string) (string, bool, error) {
+		value, found := attributes[name]
+		return value, found, nil
+	}
+	identity.AssertAttributeValueStub = func(name string, value string) error {
+		if actual, found := attributes[name]; !found || actual != value {
+			return fmt.Errorf("attribute %s is not %s", name, value)
+		}
+		return nil
+	}
+
+	state := make(map[string][]byte)
+	for _, asset := range assets {
+		assetJSON, err := json.Marshal(asset)
+		if err != nil {
+			return nil, err
+		}
+		assetType, id := asset.(storedAsset).key()
+		key, err := shim.CreateCompositeKey(assetType, []string{id})
+		if err != nil {
+			return nil, err
+		}
+		state[key] = assetJSON
+	}
+	chaincodeStub := &mocks.ChaincodeStub{}
+	chaincodeStub.CreateCompositeKeyStub = shim.CreateCompositeKey
+	chaincodeStub.GetStateStub = func(key string) ([]byte, error) {
+		return state[key], nil
+	}
+
+	transactionContext := &mocks.TransactionContext{}
+	transactionContext.GetStubReturns(chaincodeStub)
+	transactionContext.GetClientIdentityReturns(identity)
+	return transactionContext, nil
+}
+
+func TestAccessControl(t *testing.T) {
+	assets := map[string]interface{}{
+		"Supplier1": Supplier{AssetType: "Supplier", Supplier_ID: "Supplier1", Supplier_name: "Hydrogen1", MSP_ID: "Org2MSP"},
+		"Supplier2": Supplier{AssetType: "Supplier", Supplier_ID: "Supplier2", Supplier_name: "EfficentCells", MSP_ID: "Org2MSP"},
+		"Bill1":     Bill{AssetType: "Bill", Bill_ID: "Bill1", Supplier_ID: "Supplier1", Fuelcell_ID: "FuelCell1", Date_from: 20200101, Date_to: 20200131, Currency: "GBP", Amount: "31.50", Status: BillIssued},
+	}
+	fleetOperator := map[string]string{roleAttribute: RoleFleetOperator}
+	billingAdmin := map[string]string{roleAttribute: RoleBillingAdmin}
+	supplier1 := map[string]string{roleAttribute: RoleSupplier, supplierAttribute: "Supplier1"}
+	supplier2 := map[string]string{roleAttribute: RoleSupplier, supplierAttribute: "Supplier2"}
+
+	tests := []struct {
+		name       string
+		mspID      string
+		attributes map[string]string
+		check      func(ctx contractapi.TransactionContextInterface) error
+		allowed    bool
+	}{
+		{"fleet operator", "Org1MSP", fleetOperator, assertFleetOperator, true},
+		{"fleet role from another organisation", "Org2MSP", fleetOperator, assertFleetOperator, false},
+		{"fleet organisation without the role", "Org1MSP", nil, assertFleetOperator, false},
+		{"billing admin", "Org1MSP", billingAdmin, assertBillingAdmin, true},
+		{"fleet operator generating bills", "Org1MSP", fleetOperator, assertBillingAdmin, false},
+		{"billing admin role from a supplier", "Org2MSP", billingAdmin, assertBillingAdmin, false},
+		{"supplier acting for itself", "Org2MSP", supplier1, func(ctx contractapi.TransactionContextInterface) error {
+			return (&SmartContract{}).assertSupplierClient(ctx, "Supplier1")
+		}, true},
+		{"supplier acting for another supplier in its organisation", "Org2MSP", supplier2, func(ctx contractapi.TransactionContextInterface) error {
+			return (&SmartContract{}).assertSupplierClient(ctx, "Supplier1")
+		}, false},
+		{"supplier role from the wrong organisation", "Org1MSP", supplier1, func(ctx contractapi.TransactionContextInterface) error {
+			return (&SmartContract{}).assertSupplierClient(ctx, "Supplier1")
+		}, false},
+		{"fleet operator generating a bill", "Org1MSP", fleetOperator, func(ctx contractapi.TransactionContextInterface) error {
+			return (&SmartContract{}).GenerateBill(ctx, "Bill2", "FuelCell1", "20200201", "20200229")
+		}, false},
+		{"supplier recording a journey", "Org2MSP", supplier1, func(ctx contractapi.TransactionContextInterface) error {
+			return (&SmartContract{}).CreateJourney(ctx, "Journey7", "Car1", "Component1", "0", "10", "1", 0.5, "Supplier1", "20200201")
+		}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, err := clientWith(test.mspID, test.attributes, assets)
+			require.NoError(t, err)
+			err = test.check(ctx)
+			if test.allowed {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestReadBillAccess(t *testing.T) {
+	assets := map[string]interface{}{
+		"Supplier1": Supplier{AssetType: "Supplier", Supplier_ID: "Supplier1", Supplier_name: "Hydrogen1", MSP_ID: "Org2MSP"},
+		"Supplier2": Supplier{AssetType: "Supplier", Supplier_ID: "Supplier2", Supplier_name: "EfficentCells", MSP_ID: "Org2MSP"},
+		"Bill1":     Bill{AssetType: "Bill", Bill_ID: "Bill1", Supplier_ID: "Supplier1", Fuelcell_ID: "FuelCell1", Date_from: 20200101, Date_to: 20200131, Currency: "GBP", Amount: "31.50", Status: BillIssued},
+	}
+	tests := []struct {
+		name       string
+		mspID      string
+		attributes map[string]string
+		allowed    bool
+	}{
+		{"fleet operator", "Org1MSP", map[string]string{roleAttribute: RoleFleetOperator}, true},
+		{"billing admin", "Org1MSP", map[string]string{roleAttribute: RoleBillingAdmin}, true},
+		{"supplier the bill is addressed to", "Org2MSP", map[string]string{roleAttribute: RoleSupplier, supplierAttribute: "Supplier1"}, true},
+		{"another supplier", "Org2MSP", map[string]string{roleAttribute: RoleSupplier, supplierAttribute: "Supplier2"}, false},
+		{"supplier without a supplier ID", "Org2MSP", map[string]string{roleAttribute: RoleSupplier}, false},
+		{"client without a role", "Org1MSP", nil, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, err := clientWith(test.mspID, test.attributes, assets)
+			require.NoError(t, err)
+			bill, err := (&SmartContract{}).ReadBill(ctx, "Bill1")
+			if test.allowed {
+				require.NoError(t, err)
+				require.Equal(t, "Bill1", bill.Bill_ID)
+			} else {
+				require.Error(t, err)
+				require.Nil(t, bill)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package chaincode
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Fleet analytics. Journeys are aggregated in the chaincode so reviews of a car, component, fuel cell or
+// supplier get their figures back without every journey being sent to the client. Sums are kept exact
+// and only converted to floating point at the end, so every endorser returns the same figures. These
+// are evaluate only queries, and like the journey queries they read any journey on the channel.
+
+// JourneyStats are the totals of the journeys made by a car, component, fuel cell or supplier over a period
+type JourneyStats struct {
+	Scope               string  `json:"Scope"` // Car, Car_Component, Fuelcell or Supplier
+	ID                  string  `json:"ID"`
+	Date_from           int     `json:"Date_from"`
+	Date_to             int     `json:"Date_to"`
+	Journeys            int     `json:"Journeys"`
+	Total_distance      int     `json:"Total_distance"`      // km
+	Total_H2_used       int     `json:"Total_H2_used"`       // grammes
+	Average_efficiency  float64 `json:"Average_efficiency"`  // mean Efficiency of the journeys
+	Weighted_efficiency float64 `json:"Weighted_efficiency"` // Efficiency weighted by the H2 each journey used
+	Distance_per_H2     float64 `json:"Distance_per_H2"`     // km per kg of H2, 0 if no H2 was used
+}
+
+// journeyTotals accumulates the exact sums behind JourneyStats
+type journeyTotals struct {
+	journeys           int
+	distance           int
+	h2Used             int
+	efficiency         *big.Rat // sum of Efficiency
+	weightedEfficiency *big.Rat // sum of H2_used * Efficiency
+}
+
+// GetJourneyStats returns the totals of the journeys made between the dates by the asset of the given
+// scope: a Car, a Car_Component, a Fuelcell across every component it was fitted as, or a Supplier
+// across every fuel cell it held during the period
+func (s *SmartContract) GetJourneyStats(ctx contractapi.TransactionContextInterface, scope string, id string, startDate string, endDate string) (*JourneyStats, error) {
+	statsPeriod, err := newPeriod(startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	var journeys []*JourneyData
+	switch scope {
+	case "Car":
+		_, err = s.ReadCar(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		journeys, err = journeysByIndex(ctx, journeyByCarIndex, []string{id}, &statsPeriod)
+	case "Car_Component":
+		_, err = s.ReadCarComponent(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		journeys, err = journeysByIndex(ctx, journeyByComponentIndex, []string{id}, &statsPeriod)
+	case "Fuelcell":
+		_, err = s.ReadFuelcell(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		journeys, err = s.fuelcellJourneys(ctx, id, statsPeriod)
+	case "Supplier":
+		_, err = s.ReadSupplier(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		journeys, err = s.supplierJourneys(ctx, id, statsPeriod)
+	default:
+		return nil, fmt.Errorf("cannot total journeys by %s, expected Car, Car_Component, Fuelcell or Supplier", scope)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	totals := journeyTotals{efficiency: new(big.Rat), weightedEfficiency: new(big.Rat)}
+	for _, journey := range journeys {
+		err = totals.add(journey)
+		if err != nil {
+			return nil, err
+		}
+	}
+	stats := totals.stats()
+	stats.Scope = scope
+	stats.ID = id
+	stats.Date_from = ledgerDate(statsPeriod.from)
+	stats.Date_to = ledgerDate(statsPeriod.to)
+	return stats, nil
+}
+
+// fuelcellJourneys returns the journeys made within the period by every component the fuel cell was fitted as
+func (s *SmartContract) fuelcellJourneys(ctx contractapi.TransactionContextInterface, fuelcellID string, within period) ([]*JourneyData, error) {
+	components, err := componentsForFuelcell(ctx, fuelcellID)
+	if err != nil {
+		return nil, err
+	}
+	var journeys []*JourneyData
+	for _, component := range components {
+		placement, ok, err := placementPeriod(component)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		fitted, ok := placement.intersect(within)
+		if !ok {
+			continue
+		}
+		componentJourneys, err := journeysByIndex(ctx, journeyByComponentIndex, []string{component.Car_Component_ID}, &fitted)
+		if err != nil {
+			return nil, err
+		}
+		journeys = append(journeys, componentJourneys...)
+	}
+	return journeys, nil
+}
+
+// supplierJourneys returns the journeys made within the period on every fuel cell the supplier held during it
+func (s *SmartContract) supplierJourneys(ctx contractapi.TransactionContextInterface, supplierID string, within period) ([]*JourneyData, error) {
+	fuelcells, err := s.GetAllSuppliersFuelCellsBetweenDates(ctx, supplierID, within.from.Format(ledgerDateLayout), within.to.Format(ledgerDateLayout))
+	if err != nil {
+		return nil, err
+	}
+	var journeys []*JourneyData
+	for _, fuelcell := range fuelcells {
+		heldPeriod, err := ledgerPeriod(fuelcell.Date_Received, fuelcell.Date_Returned)
+		if err != nil {
+			return nil, err
+		}
+		held, _ := heldPeriod.intersect(within)
+		fuelcellJourneys, err := s.fuelcellJourneys(ctx, fuelcell.Fuelcell_ID, held)
+		if err != nil {
+			return nil, err
+		}
+		journeys = append(journeys, fuelcellJourneys...)
+	}
+	return journeys, nil
+}
+
+// add counts a journey into the totals
+func (t *journeyTotals) add(journey *JourneyData) error {
+	efficiency, err := journeyEfficiency(journey)
+	if err != nil {
+		return fmt.Errorf("invalid Efficiency on Journey %s: %v", journey.Journey_ID, err)
+	}
+	t.journeys++
+	t.distance += journey.Distance
+	t.h2Used += journey.H2_used
+	t.efficiency.Add(t.efficiency, efficiency)
+	t.weightedEfficiency.Add(t.weightedEfficiency, new(big.Rat).Mul(big.NewRat(int64(journey.H2_used), 1), efficiency))
+	return nil
+}
+
+// stats returns the averages and ratios of the totals; each is 0 when there is nothing to divide by
+func (t *journeyTotals) stats() *JourneyStats {
+	stats := &JourneyStats{Journeys: t.journeys, Total_distance: t.distance, Total_H2_used: t.h2Used}
+	if t.journeys > 0 {
+		stats.Average_efficiency, _ = new(big.Rat).Quo(t.efficiency, big.NewRat(int64(t.journeys), 1)).Float64()
+	}
+	if t.h2Used > 0 {
+		h2Used := big.NewRat(int64(t.h2Used), 1)
+		stats.Weighted_efficiency, _ = new(big.Rat).Quo(t.weightedEfficiency, h2Used).Float64()
+		// H2_used is recorded in grammes, so scale to km per kg
+		stats.Distance_per_H2, _ = new(big.Rat).Quo(big.NewRat(int64(t.distance)*1000, 1), h2Used).Float64()
+	}
+	return stats
+}
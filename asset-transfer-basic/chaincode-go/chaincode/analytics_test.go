@@ -0,0 +1,67 @@
+package chaincode_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/simulator"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetJourneyStats(t *testing.T) {
+	ledger := simulator.NewLedger(time.Date(2022, time.March, 2, 9, 0, 0, 0, time.UTC))
+	contract := &chaincode.SmartContract{}
+	require.NoError(t, ledger.Submit(adminClient, contract.InitLedger))
+
+	tests := []struct {
+		name      string
+		scope     string
+		id        string
+		startDate string
+		endDate   string
+		journeys  int
+		distance  int
+		h2Used    int
+		average   float64
+		weighted  float64
+		perH2     float64
+	}{
+		{"car", "Car", "Car1", "20200101", "20201231", 2, 1500, 250, 0.4, 0.42, 6000},
+		{"component", "Car_Component", "Component1", "20200101", "20211231", 3, 2000, 350, 0.3667, 0.3857, 5714.2857},
+		{"fuel cell across components", "Fuelcell", "FuelCell2", "20210101", "20210630", 2, 1000, 200, 0.3, 0.3, 5000},
+		{"supplier across fuel cells", "Supplier", "Supplier2", "2021-01-01", "2021-12-31", 3, 1500, 300, 0.3, 0.3, 5000},
+		{"no journeys", "Car", "Car3", "20200101", "20201231", 0, 0, 0, 0, 0, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stats *chaincode.JourneyStats
+			err := ledger.Evaluate(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+				var err error
+				stats, err = contract.GetJourneyStats(ctx, test.scope, test.id, test.startDate, test.endDate)
+				return err
+			})
+			require.NoError(t, err)
+			require.Equal(t, test.scope, stats.Scope)
+			require.Equal(t, test.id, stats.ID)
+			require.Equal(t, test.journeys, stats.Journeys)
+			require.Equal(t, test.distance, stats.Total_distance)
+			require.Equal(t, test.h2Used, stats.Total_H2_used)
+			require.InDelta(t, test.average, stats.Average_efficiency, 0.0001)
+			require.InDelta(t, test.weighted, stats.Weighted_efficiency, 0.0001)
+			require.InDelta(t, test.perH2, stats.Distance_per_H2, 0.0001)
+		})
+	}
+
+	for scope, expected := range map[string]string{
+		"Bill": "cannot total journeys by Bill, expected Car, Car_Component, Fuelcell or Supplier",
+		"Car":  "the Car Car9 does not exist",
+	} {
+		err := ledger.Evaluate(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+			_, err := contract.GetJourneyStats(ctx, scope, "Car9", "20200101", "20201231")
+			return err
+		})
+		require.EqualError(t, err, expected)
+	}
+}
@@ -0,0 +1,787 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Create, read, update and retire/delete transactions for the fleet records that feed billing.
+// Every write validates its foreign keys (Car_ID, Supplier_ID, Fuelcell_ID) against the world state
+// and rejects IDs which are already taken, whatever type of asset holds them.
+
+// CreateCar adds a new car to the world state
+func (s *SmartContract) CreateCar(ctx contractapi.TransactionContextInterface, carID string, dateOfManufacture string, misc string) error {
+	err := assertFleetOperator(ctx)
+	if err != nil {
+		return err
+	}
+	exists, err := assetExists(ctx, "Car", carID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("the asset %s already exists", carID)
+	}
+	manufactured, err := parseDate(dateOfManufacture)
+	if err != nil {
+		return err
+	}
+	asset := Car{
+		AssetType:           "Car",
+		Car_ID:              carID,
+		Date_of_manufacture: manufactured.Format(ledgerDateLayout),
+		Misc:                misc,
+	}
+	return putAsset(ctx, asset)
+}
+
+// ReadCar returns the car stored in the world state with the given id
+func (s *SmartContract) ReadCar(ctx contractapi.TransactionContextInterface, carID string) (*Car, error) {
+	var asset Car
+	err := readAsset(ctx, carID, "Car", &asset)
+	if err != nil {
+		return nil, err
+	}
+	return &asset, nil
+}
+
+// UpdateCar overwrites the details of an existing car
+func (s *SmartContract) UpdateCar(ctx contractapi.TransactionContextInterface, carID string, dateOfManufacture string, misc string) error {
+	err := assertFleetOperator(ctx)
+	if err != nil {
+		return err
+	}
+	asset, err := s.ReadCar(ctx, carID)
+	if err != nil {
+		return err
+	}
+	manufactured, err := parseDate(dateOfManufacture)
+	if err != nil {
+		return err
+	}
+	asset.Date_of_manufacture = manufactured.Format(ledgerDateLayout)
+	asset.Misc = misc
+	return putAsset(ctx, asset)
+}
+
+// DeleteCar removes a car which has never had a component fitted or recorded a journey
+func (s *SmartContract) DeleteCar(ctx contractapi.TransactionContextInterface, carID string) error {
+	err := assertFleetOperator(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = s.ReadCar(ctx, carID)
+	if err != nil {
+		return err
+	}
+	referenced, err := assetReferenced(ctx, selector{"AssetType": "Car_Component", "Car_ID": carID})
+	if err != nil {
+		return err
+	}
+	if referenced {
+		return fmt.Errorf("the Car %s still has car components recorded against it", carID)
+	}
+	referenced, err = assetReferenced(ctx, selector{"AssetType": "Journey", "Car_ID": carID})
+	if err != nil {
+		return err
+	}
+	if referenced {
+		return fmt.Errorf("the Car %s still has journeys recorded against it", carID)
+	}
+	return deleteAsset(ctx, "Car", carID)
+}
+
+// RetireCar records the date a car left the fleet. No fuel cell may still be fitted to it on that date,
+// and no journey may be recorded on or after it.
+func (s *SmartContract) RetireCar(ctx contractapi.TransactionContextInterface, carID string, date string) error {
+	err := assertFleetOperator(ctx)
+	if err != nil {
+		return err
+	}
+	asset, err := s.ReadCar(ctx, carID)
+	if err != nil {
+		return err
+	}
+	if asset.Date_retired != 0 {
+		return fmt.Errorf("the Car %s was already retired on %d", carID, asset.Date_retired)
+	}
+	intDate, err := parseLedgerDate(date)
+	if err != nil {
+		return err
+	}
+	components, err := componentsMatching(ctx, selector{"AssetType": "Car_Component", "Car_ID": carID})
+	if err != nil {
+		return err
+	}
+	for _, component := range components {
+		if component.Date_removed == 0 || component.Date_removed > intDate {
+			return fmt.Errorf("the Car %s still has Fuelcell %s fitted as %s", carID, component.Fuelcell_ID, component.Car_Component_ID)
+		}
+	}
+	journeys, err := s.GetAllJourneysofCar(ctx, carID)
+	if err != nil {
+		return err
+	}
+	for _, journey := range journeys {
+		if journey.Journey_date >= intDate {
+			return fmt.Errorf("the Car %s has Journey %s recorded on %d", carID, journey.Journey_ID, journey.Journey_date)
+		}
+	}
+	asset.Date_retired = intDate
+	return putAsset(ctx, asset)
+}
+
+// CreateSupplier adds a new fuel cell supplier to the world state
+func (s *SmartContract) CreateSupplier(ctx contractapi.TransactionContextInterface, supplierID string, supplierName string, mspID string, misc string) error {
+	err := assertBillingAdmin(ctx)
+	if err != nil {
+		return err
+	}
+	exists, err := assetExists(ctx, "Supplier", supplierID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("the asset %s already exists", supplierID)
+	}
+	asset := Supplier{
+		AssetType:     "Supplier",
+		Supplier_ID:   supplierID,
+		Supplier_name: supplierName,
+		MSP_ID:        mspID,
+		Misc:          misc,
+	}
+	return putAsset(ctx, asset)
+}
+
+// ReadSupplier returns the supplier stored in the world state with the given id
+func (s *SmartContract) ReadSupplier(ctx contractapi.TransactionContextInterface, supplierID string) (*Supplier, error) {
+	var asset Supplier
+	err := readAsset(ctx, supplierID, "Supplier", &asset)
+	if err != nil {
+		return nil, err
+	}
+	return &asset, nil
+}
+
+// UpdateSupplier overwrites the details of an existing supplier
+func (s *SmartContract) UpdateSupplier(ctx contractapi.TransactionContextInterface, supplierID string, supplierName string, mspID string, misc string) error {
+	err := assertBillingAdmin(ctx)
+	if err != nil {
+		return err
+	}
+	asset, err := s.ReadSupplier(ctx, supplierID)
+	if err != nil {
+		return err
+	}
+	asset.Supplier_name = supplierName
+	asset.MSP_ID = mspID
+	asset.Misc = misc
+	return putAsset(ctx, asset)
+}
+
+// DeleteSupplier removes a supplier which no longer owns any fuel cells or bills
+func (s *SmartContract) DeleteSupplier(ctx contractapi.TransactionContextInterface, supplierID string) error {
+	err := assertBillingAdmin(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = s.ReadSupplier(ctx, supplierID)
+	if err != nil {
+		return err
+	}
+	referenced, err := assetReferenced(ctx, selector{"AssetType": "Fuelcell", "Supplier_ID": supplierID})
+	if err != nil {
+		return err
+	}
+	if referenced {
+		return fmt.Errorf("the Supplier %s still has fuel cells recorded against it", supplierID)
+	}
+	referenced, err = assetReferenced(ctx, selector{"AssetType": "Bill", "Supplier_ID": supplierID})
+	if err != nil {
+		return err
+	}
+	if referenced {
+		return fmt.Errorf("the Supplier %s still has bills recorded against it", supplierID)
+	}
+	return deleteAsset(ctx, "Supplier", supplierID)
+}
+
+// RetireSupplier records the date a supplier stopped supplying fuel cells. Every fuel cell it supplied
+// must have been returned by that date and every bill addressed to it paid, voided or credited in full.
+func (s *SmartContract) RetireSupplier(ctx contractapi.TransactionContextInterface, supplierID string, date string) error {
+	err := assertBillingAdmin(ctx)
+	if err != nil {
+		return err
+	}
+	asset, err := s.ReadSupplier(ctx, supplierID)
+	if err != nil {
+		return err
+	}
+	if asset.Date_retired != 0 {
+		return fmt.Errorf("the Supplier %s was already retired on %d", supplierID, asset.Date_retired)
+	}
+	intDate, err := parseLedgerDate(date)
+	if err != nil {
+		return err
+	}
+	fuelcells, err := fuelcellsMatching(ctx, selector{"AssetType": "Fuelcell", "Supplier_ID": supplierID})
+	if err != nil {
+		return err
+	}
+	for _, fuelcell := range fuelcells {
+		if fuelcell.Date_Returned == 0 || fuelcell.Date_Returned > intDate {
+			return fmt.Errorf("the Supplier %s still has Fuelcell %s out with the fleet", supplierID, fuelcell.Fuelcell_ID)
+		}
+	}
+	bills, err := billsMatching(ctx, selector{"AssetType": "Bill", "Supplier_ID": supplierID})
+	if err != nil {
+		return err
+	}
+	for _, bill := range bills {
+		settled, err := billSettled(ctx, bill)
+		if err != nil {
+			return err
+		}
+		if !settled {
+			return fmt.Errorf("the Supplier %s still has Bill %s %s", supplierID, bill.Bill_ID, bill.Status)
+		}
+	}
+	asset.Date_retired = intDate
+	return putAsset(ctx, asset)
+}
+
+// CreateFuelcell records a fuel cell received from a supplier along with its first tariff, whose rates
+// are read from the tariff_rates entry of the transient map
+func (s *SmartContract) CreateFuelcell(ctx contractapi.TransactionContextInterface, fuelcellID string, supplierID string,
+	currency string, dateReceived string, misc string) error {
+	err := s.assertSupplierClient(ctx, supplierID)
+	if err != nil {
+		return err
+	}
+	exists, err := assetExists(ctx, "Fuelcell", fuelcellID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("the asset %s already exists", fuelcellID)
+	}
+	supplier, err := s.ReadSupplier(ctx, supplierID)
+	if err != nil {
+		return err
+	}
+	intDateReceived, err := parseLedgerDate(dateReceived)
+	if err != nil {
+		return err
+	}
+	if supplier.Date_retired != 0 && supplier.Date_retired <= intDateReceived {
+		return fmt.Errorf("the Supplier %s was retired on %d", supplierID, supplier.Date_retired)
+	}
+	code, _, err := currencyCode(currency)
+	if err != nil {
+		return err
+	}
+	rates, err := ratesFromTransient(ctx)
+	if err != nil {
+		return err
+	}
+	asset := FuelcellData{
+		AssetType:     "Fuelcell",
+		Fuelcell_ID:   fuelcellID,
+		Supplier_ID:   supplierID,
+		Currency:      code,
+		Date_Received: intDateReceived,
+		Date_Returned: 0, // 0 signifies still held
+		Misc:          misc,
+	}
+	err = putAsset(ctx, asset)
+	if err != nil {
+		return err
+	}
+	return putTariff(ctx, fuelcellID, intDateReceived, rates)
+}
+
+// ReadFuelcell returns the fuel cell stored in the world state with the given id
+func (s *SmartContract) ReadFuelcell(ctx contractapi.TransactionContextInterface, fuelcellID string) (*FuelcellData, error) {
+	var asset FuelcellData
+	err := readAsset(ctx, fuelcellID, "Fuelcell", &asset)
+	if err != nil {
+		return nil, err
+	}
+	if code, ok := currencyAliases[asset.Currency]; ok {
+		asset.Currency = code // written before currencies were ISO 4217 codes
+	}
+	return &asset, nil
+}
+
+// UpdateFuelcell overwrites the supplier and notes of an existing fuel cell.
+// Rates are changed with SetFuelcellTariff so that periods already used keep the rates they had.
+func (s *SmartContract) UpdateFuelcell(ctx contractapi.TransactionContextInterface, fuelcellID string, supplierID string, misc string) error {
+	err := s.assertFuelcellSupplier(ctx, fuelcellID)
+	if err != nil {
+		return err
+	}
+	asset, err := s.ReadFuelcell(ctx, fuelcellID)
+	if err != nil {
+		return err
+	}
+	supplier, err := s.ReadSupplier(ctx, supplierID)
+	if err != nil {
+		return err
+	}
+	if supplier.Date_retired != 0 {
+		return fmt.Errorf("the Supplier %s was retired on %d", supplierID, supplier.Date_retired)
+	}
+	asset.Supplier_ID = supplierID
+	asset.Misc = misc
+	return putAsset(ctx, asset)
+}
+
+// ReturnFuelcell marks a fuel cell as handed back to its supplier on the given date.
+// The fuel cell must already have been removed from every car it was fitted to.
+func (s *SmartContract) ReturnFuelcell(ctx contractapi.TransactionContextInterface, fuelcellID string, date string) error {
+	err := s.assertFuelcellSupplier(ctx, fuelcellID)
+	if err != nil {
+		return err
+	}
+	asset, err := s.ReadFuelcell(ctx, fuelcellID)
+	if err != nil {
+		return err
+	}
+	if asset.Date_Returned != 0 {
+		return fmt.Errorf("the Fuelcell %s was already returned on %d", fuelcellID, asset.Date_Returned)
+	}
+	intDate, err := parseLedgerDate(date)
+	if err != nil {
+		return err
+	}
+	if intDate < asset.Date_Received {
+		return fmt.Errorf("the Fuelcell %s cannot be returned before it was received on %d", fuelcellID, asset.Date_Received)
+	}
+	components, err := componentsForFuelcell(ctx, fuelcellID)
+	if err != nil {
+		return err
+	}
+	for _, component := range components {
+		if component.Date_removed == 0 || component.Date_removed > intDate {
+			return fmt.Errorf("the Fuelcell %s is still fitted to Car %s as %s", fuelcellID, component.Car_ID, component.Car_Component_ID)
+		}
+	}
+	asset.Date_Returned = intDate
+	return putAsset(ctx, asset)
+}
+
+// DeleteFuelcell removes a fuel cell which has never been fitted to a car or billed
+func (s *SmartContract) DeleteFuelcell(ctx contractapi.TransactionContextInterface, fuelcellID string) error {
+	err := s.assertFuelcellSupplier(ctx, fuelcellID)
+	if err != nil {
+		return err
+	}
+	_, err = s.ReadFuelcell(ctx, fuelcellID)
+	if err != nil {
+		return err
+	}
+	referenced, err := assetReferenced(ctx, selector{"AssetType": "Car_Component", "Fuelcell_ID": fuelcellID})
+	if err != nil {
+		return err
+	}
+	if referenced {
+		return fmt.Errorf("the Fuelcell %s still has car components recorded against it", fuelcellID)
+	}
+	referenced, err = assetReferenced(ctx, selector{"AssetType": "Bill", "Fuelcell_ID": fuelcellID})
+	if err != nil {
+		return err
+	}
+	if referenced {
+		return fmt.Errorf("the Fuelcell %s still has bills recorded against it", fuelcellID)
+	}
+	return deleteAsset(ctx, "Fuelcell", fuelcellID)
+}
+
+// CreateCarComponent records a fuel cell being fitted to a car on the given date
+func (s *SmartContract) CreateCarComponent(ctx contractapi.TransactionContextInterface, componentID string, carID string, fuelcellID string, dateAdded string) error {
+	err := assertFleetOperator(ctx)
+	if err != nil {
+		return err
+	}
+	exists, err := assetExists(ctx, "Car_Component", componentID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("the asset %s already exists", componentID)
+	}
+	car, err := s.ReadCar(ctx, carID)
+	if err != nil {
+		return err
+	}
+	fuelcell, err := s.ReadFuelcell(ctx, fuelcellID)
+	if err != nil {
+		return err
+	}
+	intDateAdded, err := parseLedgerDate(dateAdded)
+	if err != nil {
+		return err
+	}
+	err = checkCarInService(car, intDateAdded)
+	if err != nil {
+		return err
+	}
+	if intDateAdded < fuelcell.Date_Received {
+		return fmt.Errorf("the Fuelcell %s was not received until %d", fuelcellID, fuelcell.Date_Received)
+	}
+	if fuelcell.Date_Returned != 0 && fuelcell.Date_Returned <= intDateAdded {
+		return fmt.Errorf("the Fuelcell %s was returned on %d", fuelcellID, fuelcell.Date_Returned)
+	}
+	err = checkFuelcellFree(ctx, fuelcellID, intDateAdded, "")
+	if err != nil {
+		return err
+	}
+	asset := CarComponent{
+		AssetType:        "Car_Component",
+		Car_Component_ID: componentID,
+		Car_ID:           carID,
+		Fuelcell_ID:      fuelcellID,
+		Date_added:       intDateAdded,
+		Date_removed:     0, // 0 signifies still in place
+	}
+	err = putAsset(ctx, asset)
+	if err != nil {
+		return err
+	}
+	return emitEvent(ctx, EventComponentAdded, newComponentEvent(&asset))
+}
+
+// ReadCarComponent returns the car component stored in the world state with the given id
+func (s *SmartContract) ReadCarComponent(ctx contractapi.TransactionContextInterface, componentID string) (*CarComponent, error) {
+	var asset CarComponent
+	err := readAsset(ctx, componentID, "Car_Component", &asset)
+	if err != nil {
+		return nil, err
+	}
+	return &asset, nil
+}
+
+// RemoveCarComponent records the date a component was taken out of its car. No journey may already be
+// recorded on the component on or after that date.
+func (s *SmartContract) RemoveCarComponent(ctx contractapi.TransactionContextInterface, componentID string, dateRemoved string) error {
+	err := assertFleetOperator(ctx)
+	if err != nil {
+		return err
+	}
+	asset, err := s.ReadCarComponent(ctx, componentID)
+	if err != nil {
+		return err
+	}
+	if asset.Date_removed != 0 {
+		return fmt.Errorf("the Car_Component %s was already removed on %d", componentID, asset.Date_removed)
+	}
+	intDateRemoved, err := parseLedgerDate(dateRemoved)
+	if err != nil {
+		return err
+	}
+	if intDateRemoved < asset.Date_added {
+		return fmt.Errorf("the Car_Component %s cannot be removed before it was added on %d", componentID, asset.Date_added)
+	}
+	err = checkNoJourneysFrom(ctx, componentID, intDateRemoved)
+	if err != nil {
+		return err
+	}
+	asset.Date_removed = intDateRemoved
+	err = putAsset(ctx, asset)
+	if err != nil {
+		return err
+	}
+	return emitEvent(ctx, EventComponentRemoved, newComponentEvent(asset))
+}
+
+// SwapFuelcell takes the fuel cell fitted as oldComponentID out of the car on the given date and fits
+// newFuelcellID in its place from the same date, as a single transaction. No journey may already be recorded
+// on the old component on or after the date. It returns the ID of the new component.
+func (s *SmartContract) SwapFuelcell(ctx contractapi.TransactionContextInterface, carID string, oldComponentID string, newFuelcellID string, date string) (string, error) {
+	err := assertFleetOperator(ctx)
+	if err != nil {
+		return "", err
+	}
+	car, err := s.ReadCar(ctx, carID)
+	if err != nil {
+		return "", err
+	}
+	oldComponent, err := s.ReadCarComponent(ctx, oldComponentID)
+	if err != nil {
+		return "", err
+	}
+	if oldComponent.Car_ID != carID {
+		return "", fmt.Errorf("the Car_Component %s belongs to Car %s not Car %s", oldComponentID, oldComponent.Car_ID, carID)
+	}
+	if oldComponent.Date_removed != 0 {
+		return "", fmt.Errorf("the Car_Component %s was already removed on %d", oldComponentID, oldComponent.Date_removed)
+	}
+	intDate, err := parseLedgerDate(date)
+	if err != nil {
+		return "", err
+	}
+	if intDate < oldComponent.Date_added {
+		return "", fmt.Errorf("the Car_Component %s cannot be removed before it was added on %d", oldComponentID, oldComponent.Date_added)
+	}
+	err = checkNoJourneysFrom(ctx, oldComponentID, intDate)
+	if err != nil {
+		return "", err
+	}
+	err = checkCarInService(car, intDate)
+	if err != nil {
+		return "", err
+	}
+	fuelcell, err := s.ReadFuelcell(ctx, newFuelcellID)
+	if err != nil {
+		return "", err
+	}
+	if intDate < fuelcell.Date_Received {
+		return "", fmt.Errorf("the Fuelcell %s was not received until %d", newFuelcellID, fuelcell.Date_Received)
+	}
+	if fuelcell.Date_Returned != 0 && fuelcell.Date_Returned <= intDate {
+		return "", fmt.Errorf("the Fuelcell %s was returned on %d", newFuelcellID, fuelcell.Date_Returned)
+	}
+	// the old component is being closed on this date so it cannot clash with the new placement
+	err = checkFuelcellFree(ctx, newFuelcellID, intDate, oldComponentID)
+	if err != nil {
+		return "", err
+	}
+	newComponentID := fmt.Sprintf("%s_%s_%d", carID, newFuelcellID, intDate)
+	exists, err := assetExists(ctx, "Car_Component", newComponentID)
+	if err != nil {
+		return "", err
+	}
+	if exists {
+		return "", fmt.Errorf("the asset %s already exists", newComponentID)
+	}
+
+	oldComponent.Date_removed = intDate
+	err = putAsset(ctx, oldComponent)
+	if err != nil {
+		return "", err
+	}
+	newComponent := CarComponent{
+		AssetType:        "Car_Component",
+		Car_Component_ID: newComponentID,
+		Car_ID:           carID,
+		Fuelcell_ID:      newFuelcellID,
+		Date_added:       intDate,
+		Date_removed:     0, // 0 signifies still in place
+	}
+	err = putAsset(ctx, newComponent)
+	if err != nil {
+		return "", err
+	}
+	err = emitEvent(ctx, EventFuelcellSwapped, swapEvent{
+		Car_ID:               carID,
+		Old_Car_Component_ID: oldComponentID,
+		Old_Fuelcell_ID:      oldComponent.Fuelcell_ID,
+		New_Car_Component_ID: newComponentID,
+		New_Fuelcell_ID:      newFuelcellID,
+		Date:                 intDate,
+	})
+	if err != nil {
+		return "", err
+	}
+	return newComponentID, nil
+}
+
+// DeleteCarComponent removes a component record which no journey refers to
+func (s *SmartContract) DeleteCarComponent(ctx contractapi.TransactionContextInterface, componentID string) error {
+	err := assertFleetOperator(ctx)
+	if err != nil {
+		return err
+	}
+	component, err := s.ReadCarComponent(ctx, componentID)
+	if err != nil {
+		return err
+	}
+	referenced, err := assetReferenced(ctx, selector{"AssetType": "Journey", "Car_Component_ID": componentID})
+	if err != nil {
+		return err
+	}
+	if referenced {
+		return fmt.Errorf("the Car_Component %s still has journeys recorded against it", componentID)
+	}
+	err = deleteAsset(ctx, "Car_Component", componentID)
+	if err != nil {
+		return err
+	}
+	return emitEvent(ctx, EventComponentDeleted, newComponentEvent(component))
+}
+
+// readAsset unmarshals the asset of the given AssetType with the ID into asset, checking the stored
+// AssetType matches
+func readAsset(ctx contractapi.TransactionContextInterface, id string, assetType string, asset interface{}) error {
+	key, err := assetKey(ctx, assetType, id)
+	if err != nil {
+		return err
+	}
+	assetJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if assetJSON == nil {
+		return fmt.Errorf("the %s %s does not exist", assetType, id)
+	}
+	var header struct {
+		AssetType string `json:"AssetType"`
+	}
+	err = json.Unmarshal(assetJSON, &header)
+	if err != nil {
+		return err
+	}
+	if header.AssetType != assetType {
+		return fmt.Errorf("the asset %s is a %s not a %s", id, header.AssetType, assetType)
+	}
+	return json.Unmarshal(assetJSON, asset)
+}
+
+// putAsset validates asset, then marshals it and writes it to the world state under its key
+func putAsset(ctx contractapi.TransactionContextInterface, asset storedAsset) error {
+	err := asset.validate()
+	if err != nil {
+		return err
+	}
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed getting client's MSP ID: %v", err)
+	}
+	assetJSON, err := json.Marshal(asset.submittedBy(mspID))
+	if err != nil {
+		return err
+	}
+	assetType, id := asset.key()
+	key, err := assetKey(ctx, assetType, id)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().PutState(key, assetJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put to world state. %v", err)
+	}
+	return nil
+}
+
+// assetReferenced reports whether any asset matches the selector
+func assetReferenced(ctx contractapi.TransactionContextInterface, query selector) (bool, error) {
+	resultsIterator, err := ctx.GetStub().GetQueryResult(query.query())
+	if err != nil {
+		return false, err
+	}
+	defer resultsIterator.Close()
+	return resultsIterator.HasNext(), nil
+}
+
+// checkFuelcellFree returns an error if the fuel cell is fitted to any car on or after from.
+// A placement runs from Date_added up to, but not including, Date_removed so a cell may be
+// removed from one car and fitted to another on the same day. The component excludeID is ignored.
+func checkFuelcellFree(ctx contractapi.TransactionContextInterface, fuelcellID string, from int, excludeID string) error {
+	components, err := componentsForFuelcell(ctx, fuelcellID)
+	if err != nil {
+		return err
+	}
+	for _, component := range components {
+		if component.Car_Component_ID == excludeID {
+			continue
+		}
+		if component.Date_removed == 0 || from < component.Date_removed {
+			return fmt.Errorf("the Fuelcell %s is already fitted to Car %s as %s from %d", fuelcellID, component.Car_ID, component.Car_Component_ID, component.Date_added)
+		}
+	}
+	return nil
+}
+
+// placementPeriod returns the days a component was fitted. As for checkFuelcellFree a placement ends the
+// day before Date_removed, so on the day of a swap the fuel cell is only fitted to its new car; ok is
+// false for a component removed on the day it was added.
+func placementPeriod(component *CarComponent) (placement period, ok bool, err error) {
+	placement, err = ledgerPeriod(component.Date_added, component.Date_removed)
+	if err != nil {
+		return period{}, false, err
+	}
+	if component.Date_removed != 0 {
+		placement.to = placement.to.AddDate(0, 0, -1)
+	}
+	return placement, !placement.to.Before(placement.from), nil
+}
+
+// checkNoJourneysFrom returns an error if journeys are recorded on the component on or after the date
+// it would be removed, as they would fall outside its placement and never be billed
+func checkNoJourneysFrom(ctx contractapi.TransactionContextInterface, componentID string, dateRemoved int) error {
+	from, err := ledgerPeriod(dateRemoved, 0)
+	if err != nil {
+		return err
+	}
+	journeys, err := journeysByIndex(ctx, journeyByComponentIndex, []string{componentID}, &from)
+	if err != nil {
+		return err
+	}
+	if len(journeys) > 0 {
+		return fmt.Errorf("the Car_Component %s has Journey %s recorded on %d", componentID, journeys[0].Journey_ID, journeys[0].Journey_date)
+	}
+	return nil
+}
+
+// checkCarInService returns an error if the car had been retired by the date
+func checkCarInService(car *Car, date int) error {
+	if car.Date_retired != 0 && car.Date_retired <= date {
+		return fmt.Errorf("the Car %s was retired on %d", car.Car_ID, car.Date_retired)
+	}
+	return nil
+}
+
+// componentsForFuelcell returns every car component the fuel cell has ever been fitted as
+func componentsForFuelcell(ctx contractapi.TransactionContextInterface, fuelcellID string) ([]*CarComponent, error) {
+	return componentsMatching(ctx, selector{"AssetType": "Car_Component", "Fuelcell_ID": fuelcellID})
+}
+
+// componentsMatching returns the car components matching the selector
+func componentsMatching(ctx contractapi.TransactionContextInterface, query selector) ([]*CarComponent, error) {
+	resultsIterator, err := ctx.GetStub().GetQueryResult(query.query())
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+	var assets []*CarComponent
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var asset CarComponent
+		err = json.Unmarshal(queryResult.Value, &asset)
+		if err != nil {
+			return nil, err
+		}
+		assets = append(assets, &asset)
+	}
+
+	return assets, nil
+}
+
+// fuelcellsMatching returns the fuel cells matching the selector
+func fuelcellsMatching(ctx contractapi.TransactionContextInterface, query selector) ([]*FuelcellData, error) {
+	resultsIterator, err := ctx.GetStub().GetQueryResult(query.query())
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+	var assets []*FuelcellData
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var asset FuelcellData
+		err = json.Unmarshal(queryResult.Value, &asset)
+		if err != nil {
+			return nil, err
+		}
+		assets = append(assets, &asset)
+	}
+	return assets, nil
+}
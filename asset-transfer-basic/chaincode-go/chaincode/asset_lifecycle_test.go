@@ -0,0 +1,446 @@
+package chaincode_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/simulator"
+	"github.com/stretchr/testify/require"
+)
+
+var supplier9Client = &simulator.Client{ID: "supplier9", MSPID: "Org2MSP", Attributes: map[string]string{"billing.role": chaincode.RoleSupplier, "billing.supplier_id": "Supplier9"}}
+
+// lifecycleLedger holds Car9 and Supplier9, whose FuelCell9 was received on 1 January 2020
+func lifecycleLedger(t *testing.T) *simulator.Ledger {
+	ledger := simulator.NewLedger(time.Date(2020, time.March, 2, 9, 0, 0, 0, time.UTC))
+	contract := &chaincode.SmartContract{}
+	require.NoError(t, ledger.Submit(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.CreateCar(ctx, "Car9", "20191201", "")
+	}))
+	require.NoError(t, ledger.Submit(adminClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.CreateSupplier(ctx, "Supplier9", "Hydrogen9", "Org2MSP", "")
+	}))
+	require.NoError(t, createFuelcell(ledger, supplier9Client, "FuelCell9", "Supplier9", "20200101"))
+	return ledger
+}
+
+// createFuelcell submits CreateFuelcell with its rates in the transient map
+func createFuelcell(ledger *simulator.Ledger, client *simulator.Client, fuelcellID string, supplierID string, dateReceived string) error {
+	ctx, stub := ledger.NewContext(client)
+	stub.SetTransient(map[string][]byte{
+		"tariff_rates": []byte(`{"Base_rate":"1","Distance_rate":"0.2","Energy_rate":"1","Salt":"asset lifecycle test salt"}`),
+	})
+	err := (&chaincode.SmartContract{}).CreateFuelcell(ctx, fuelcellID, supplierID, "GBP", dateReceived, "")
+	if err != nil {
+		return err
+	}
+	return stub.Commit()
+}
+
+func TestCarLifecycle(t *testing.T) {
+	ledger := lifecycleLedger(t)
+	contract := &chaincode.SmartContract{}
+	readCar := func(carID string) (*chaincode.Car, error) {
+		var car *chaincode.Car
+		err := ledger.Evaluate(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			car, err = contract.ReadCar(ctx, carID)
+			return err
+		})
+		return car, err
+	}
+
+	err := ledger.Submit(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.CreateCar(ctx, "Car9", "20200101", "")
+	})
+	require.EqualError(t, err, "the asset Car9 already exists")
+	err = ledger.Submit(adminClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.CreateCar(ctx, "Car8", "20200101", "")
+	})
+	require.Error(t, err, "only fleet operators add cars")
+	_, err = readCar("Car8")
+	require.EqualError(t, err, "the Car Car8 does not exist")
+
+	require.NoError(t, ledger.Submit(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.UpdateCar(ctx, "Car9", "20191202", "resprayed")
+	}))
+	car, err := readCar("Car9")
+	require.NoError(t, err)
+	require.Equal(t, chaincode.Car{AssetType: "Car", Car_ID: "Car9", Date_of_manufacture: "20191202", Misc: "resprayed", Last_submitted_by: "Org1MSP"}, *car)
+
+	// a car is retired, not deleted, once a fuel cell has been fitted to it
+	require.NoError(t, ledger.Submit(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.CreateCarComponent(ctx, "Component9", "Car9", "FuelCell9", "20200201")
+	}))
+	err = ledger.Submit(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.DeleteCar(ctx, "Car9")
+	})
+	require.EqualError(t, err, "the Car Car9 still has car components recorded against it")
+	retireCar := func(date string) error {
+		return ledger.Submit(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+			return contract.RetireCar(ctx, "Car9", date)
+		})
+	}
+	require.EqualError(t, retireCar("20200301"), "the Car Car9 still has Fuelcell FuelCell9 fitted as Component9")
+	require.NoError(t, ledger.Submit(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.RemoveCarComponent(ctx, "Component9", "20200301")
+	}))
+	require.NoError(t, retireCar("20200301"))
+	require.EqualError(t, retireCar("20200302"), "the Car Car9 was already retired on 20200301")
+	car, err = readCar("Car9")
+	require.NoError(t, err)
+	require.Equal(t, 20200301, car.Date_retired)
+
+	err = ledger.Submit(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.CreateCarComponent(ctx, "Component10", "Car9", "FuelCell9", "20200301")
+	})
+	require.EqualError(t, err, "the Car Car9 was retired on 20200301")
+	err = ledger.Submit(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.CreateJourney(ctx, "Journey9", "Car9", "Component9", "0", "10", "1", 0.5, "Supplier9", "20200301")
+	})
+	require.EqualError(t, err, "the Car Car9 was retired on 20200301")
+
+	// a car nothing refers to can be deleted outright
+	require.NoError(t, ledger.Submit(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.CreateCar(ctx, "Car8", "20200101", "")
+	}))
+	require.NoError(t, ledger.Submit(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.DeleteCar(ctx, "Car8")
+	}))
+	_, err = readCar("Car8")
+	require.EqualError(t, err, "the Car Car8 does not exist")
+}
+
+func TestSupplierLifecycle(t *testing.T) {
+	ledger := lifecycleLedger(t)
+	contract := &chaincode.SmartContract{}
+	readSupplier := func(supplierID string) (*chaincode.Supplier, error) {
+		var supplier *chaincode.Supplier
+		err := ledger.Evaluate(adminClient, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			supplier, err = contract.ReadSupplier(ctx, supplierID)
+			return err
+		})
+		return supplier, err
+	}
+
+	err := ledger.Submit(adminClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.CreateSupplier(ctx, "Supplier9", "Hydrogen9", "Org2MSP", "")
+	})
+	require.EqualError(t, err, "the asset Supplier9 already exists")
+	err = ledger.Submit(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.CreateSupplier(ctx, "Supplier8", "Hydrogen8", "Org2MSP", "")
+	})
+	require.Error(t, err, "only billing admins register suppliers")
+
+	require.NoError(t, ledger.Submit(adminClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.UpdateSupplier(ctx, "Supplier9", "Hydrogen Nine", "Org2MSP", "renamed")
+	}))
+	supplier, err := readSupplier("Supplier9")
+	require.NoError(t, err)
+	require.Equal(t, chaincode.Supplier{AssetType: "Supplier", Supplier_ID: "Supplier9", Supplier_name: "Hydrogen Nine", MSP_ID: "Org2MSP", Misc: "renamed", Last_submitted_by: "Org1MSP"}, *supplier)
+
+	err = ledger.Submit(adminClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.DeleteSupplier(ctx, "Supplier9")
+	})
+	require.EqualError(t, err, "the Supplier Supplier9 still has fuel cells recorded against it")
+
+	// a supplier is retired once its fuel cells are back and its bills settled
+	retireSupplier := func(date string) error {
+		return ledger.Submit(adminClient, func(ctx contractapi.TransactionContextInterface) error {
+			return contract.RetireSupplier(ctx, "Supplier9", date)
+		})
+	}
+	require.EqualError(t, retireSupplier("20200301"), "the Supplier Supplier9 still has Fuelcell FuelCell9 out with the fleet")
+	require.NoError(t, ledger.Submit(supplier9Client, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.ReturnFuelcell(ctx, "FuelCell9", "20200301")
+	}))
+	require.NoError(t, ledger.Submit(adminClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.GenerateBill(ctx, "Bill9", "FuelCell9", "20200101", "20200229")
+	}))
+	require.EqualError(t, retireSupplier("20200301"), "the Supplier Supplier9 still has Bill Bill9 Issued")
+	require.NoError(t, ledger.Submit(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.AcknowledgeBill(ctx, "Bill9")
+	}))
+	require.NoError(t, ledger.Submit(supplier9Client, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.MarkBillPaid(ctx, "Bill9")
+	}))
+	require.NoError(t, retireSupplier("20200301"))
+	require.EqualError(t, retireSupplier("20200302"), "the Supplier Supplier9 was already retired on 20200301")
+	supplier, err = readSupplier("Supplier9")
+	require.NoError(t, err)
+	require.Equal(t, 20200301, supplier.Date_retired)
+	require.EqualError(t, createFuelcell(ledger, supplier9Client, "FuelCell10", "Supplier9", "20200301"), "the Supplier Supplier9 was retired on 20200301")
+
+	// a supplier nothing refers to can be deleted outright
+	require.NoError(t, ledger.Submit(adminClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.CreateSupplier(ctx, "Supplier8", "Hydrogen8", "Org2MSP", "")
+	}))
+	require.NoError(t, ledger.Submit(adminClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.DeleteSupplier(ctx, "Supplier8")
+	}))
+	_, err = readSupplier("Supplier8")
+	require.EqualError(t, err, "the Supplier Supplier8 does not exist")
+}
+
+func TestRetireSupplierWithUnsettledBills(t *testing.T) {
+	contract := &chaincode.SmartContract{}
+	retireSupplier := func(ledger *simulator.Ledger) error {
+		return ledger.Submit(adminClient, func(ctx contractapi.TransactionContextInterface) error {
+			return contract.RetireSupplier(ctx, "Supplier9", "20200301")
+		})
+	}
+	returnedLedger := func() *simulator.Ledger {
+		ledger := lifecycleLedger(t)
+		require.NoError(t, ledger.Submit(supplier9Client, func(ctx contractapi.TransactionContextInterface) error {
+			return contract.ReturnFuelcell(ctx, "FuelCell9", "20200301")
+		}))
+		return ledger
+	}
+
+	// a bill written before bills had a status is Issued and still owed
+	ledger := returnedLedger()
+	legacy := ledger.NewStub()
+	key, err := legacy.CreateCompositeKey("Bill", []string{"Bill8"})
+	require.NoError(t, err)
+	require.NoError(t, legacy.PutState(key, []byte(`{"AssetType":"Bill","Bill_ID":"Bill8","Supplier_ID":"Supplier9","Fuelcell_ID":"FuelCell9","Date_from":20200101,"Date_to":20200131,"Currency":"GBP","Amount":"31.00"}`)))
+	require.NoError(t, legacy.Commit())
+	require.EqualError(t, retireSupplier(ledger), "the Supplier Supplier9 still has Bill Bill8 Issued")
+
+	// a bill credited in full is settled, while one credited in part still has a balance to pay
+	ledger = returnedLedger()
+	for billID, period := range map[string][2]string{"Bill9": {"20200101", "20200131"}, "Bill10": {"20200201", "20200229"}} {
+		billID, period := billID, period
+		require.NoError(t, ledger.Submit(adminClient, func(ctx contractapi.TransactionContextInterface) error {
+			return contract.GenerateBill(ctx, billID, "FuelCell9", period[0], period[1])
+		}))
+		require.NoError(t, ledger.Submit(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+			return contract.DisputeBill(ctx, billID, "not ours")
+		}))
+	}
+	var bill9 *chaincode.Bill
+	require.NoError(t, ledger.Evaluate(adminClient, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		bill9, err = contract.ReadBill(ctx, "Bill9")
+		return err
+	}))
+	require.NoError(t, ledger.Submit(supplier9Client, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.IssueCreditNote(ctx, "Bill9", "Credit9", string(bill9.Amount), "agreed")
+	}))
+	require.NoError(t, ledger.Submit(supplier9Client, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.IssueCreditNote(ctx, "Bill10", "Credit10", "1.00", "one day off")
+	}))
+	require.EqualError(t, retireSupplier(ledger), "the Supplier Supplier9 still has Bill Bill10 Credited")
+	require.NoError(t, ledger.Submit(supplier9Client, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.MarkBillPaid(ctx, "Bill10")
+	}))
+	require.NoError(t, retireSupplier(ledger))
+}
+
+func TestFuelcellLifecycle(t *testing.T) {
+	ledger := lifecycleLedger(t)
+	contract := &chaincode.SmartContract{}
+	readFuelcell := func(fuelcellID string) (*chaincode.FuelcellData, error) {
+		var fuelcell *chaincode.FuelcellData
+		err := ledger.Evaluate(supplier9Client, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			fuelcell, err = contract.ReadFuelcell(ctx, fuelcellID)
+			return err
+		})
+		return fuelcell, err
+	}
+
+	require.EqualError(t, createFuelcell(ledger, supplier9Client, "FuelCell9", "Supplier9", "20200101"), "the asset FuelCell9 already exists")
+	require.Error(t, createFuelcell(ledger, fleetClient, "FuelCell8", "Supplier9", "20200101"), "only the supplier records its fuel cells")
+	require.EqualError(t, createFuelcell(ledger, supplier9Client, "FuelCell8", "Supplier9", "2020-13-01"), "invalid date \"2020-13-01\": expected YYYYMMDD or ISO 8601")
+
+	require.NoError(t, ledger.Submit(supplier9Client, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.UpdateFuelcell(ctx, "FuelCell9", "Supplier9", "serviced")
+	}))
+	fuelcell, err := readFuelcell("FuelCell9")
+	require.NoError(t, err)
+	require.Equal(t, chaincode.FuelcellData{AssetType: "Fuelcell", Fuelcell_ID: "FuelCell9", Supplier_ID: "Supplier9", Currency: "GBP", Date_Received: 20200101, Misc: "serviced", Last_submitted_by: "Org2MSP"}, *fuelcell)
+
+	// a fuel cell only moves to a supplier still trading
+	moveFuelcell := func(supplierID string) error {
+		return ledger.Submit(supplier9Client, func(ctx contractapi.TransactionContextInterface) error {
+			return contract.UpdateFuelcell(ctx, "FuelCell9", supplierID, "moved")
+		})
+	}
+	require.EqualError(t, moveFuelcell("Supplier7"), "the Supplier Supplier7 does not exist")
+	require.NoError(t, ledger.Submit(adminClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.CreateSupplier(ctx, "Supplier8", "Hydrogen8", "Org2MSP", "")
+	}))
+	require.NoError(t, ledger.Submit(adminClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.RetireSupplier(ctx, "Supplier8", "20200201")
+	}))
+	require.EqualError(t, moveFuelcell("Supplier8"), "the Supplier Supplier8 was retired on 20200201")
+	fuelcell, err = readFuelcell("FuelCell9")
+	require.NoError(t, err)
+	require.Equal(t, "Supplier9", fuelcell.Supplier_ID)
+
+	returnFuelcell := func(date string) error {
+		return ledger.Submit(supplier9Client, func(ctx contractapi.TransactionContextInterface) error {
+			return contract.ReturnFuelcell(ctx, "FuelCell9", date)
+		})
+	}
+	require.EqualError(t, returnFuelcell("20191231"), "the Fuelcell FuelCell9 cannot be returned before it was received on 20200101")
+	require.NoError(t, ledger.Submit(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.CreateCarComponent(ctx, "Component9", "Car9", "FuelCell9", "20200201")
+	}))
+	require.EqualError(t, returnFuelcell("20200301"), "the Fuelcell FuelCell9 is still fitted to Car Car9 as Component9")
+	err = ledger.Submit(supplier9Client, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.DeleteFuelcell(ctx, "FuelCell9")
+	})
+	require.EqualError(t, err, "the Fuelcell FuelCell9 still has car components recorded against it")
+	require.NoError(t, ledger.Submit(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.RemoveCarComponent(ctx, "Component9", "20200215")
+	}))
+	require.NoError(t, returnFuelcell("20200301"))
+	require.EqualError(t, returnFuelcell("20200302"), "the Fuelcell FuelCell9 was already returned on 20200301")
+	fuelcell, err = readFuelcell("FuelCell9")
+	require.NoError(t, err)
+	require.Equal(t, 20200301, fuelcell.Date_Returned)
+
+	// a fuel cell never fitted or billed can be deleted outright
+	require.NoError(t, createFuelcell(ledger, supplier9Client, "FuelCell8", "Supplier9", "20200201"))
+	require.NoError(t, ledger.Submit(supplier9Client, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.DeleteFuelcell(ctx, "FuelCell8")
+	}))
+	_, err = readFuelcell("FuelCell8")
+	require.EqualError(t, err, "the Fuelcell FuelCell8 does not exist")
+}
+
+func TestCarComponentLifecycle(t *testing.T) {
+	ledger := lifecycleLedger(t)
+	contract := &chaincode.SmartContract{}
+	createComponent := func(componentID string, carID string, fuelcellID string, dateAdded string) error {
+		return ledger.Submit(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+			return contract.CreateCarComponent(ctx, componentID, carID, fuelcellID, dateAdded)
+		})
+	}
+	removeComponent := func(componentID string, dateRemoved string) error {
+		return ledger.Submit(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+			return contract.RemoveCarComponent(ctx, componentID, dateRemoved)
+		})
+	}
+	deleteComponent := func(componentID string) error {
+		return ledger.Submit(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+			return contract.DeleteCarComponent(ctx, componentID)
+		})
+	}
+
+	require.EqualError(t, createComponent("Component9", "Car8", "FuelCell9", "20200201"), "the Car Car8 does not exist")
+	require.EqualError(t, createComponent("Component9", "Car9", "FuelCell8", "20200201"), "the Fuelcell FuelCell8 does not exist")
+	require.EqualError(t, createComponent("Component9", "Car9", "FuelCell9", "20191231"), "the Fuelcell FuelCell9 was not received until 20200101")
+	require.NoError(t, createComponent("Component9", "Car9", "FuelCell9", "20200201"))
+	require.EqualError(t, createComponent("Component9", "Car9", "FuelCell9", "20200201"), "the asset Component9 already exists")
+	require.NoError(t, ledger.Submit(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.CreateCar(ctx, "Car8", "20191201", "")
+	}))
+	require.EqualError(t, createComponent("Component8", "Car8", "FuelCell9", "20200210"), "the Fuelcell FuelCell9 is already fitted to Car Car9 as Component9 from 20200201")
+
+	var component *chaincode.CarComponent
+	require.NoError(t, ledger.Evaluate(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		component, err = contract.ReadCarComponent(ctx, "Component9")
+		return err
+	}))
+	require.Equal(t, chaincode.CarComponent{AssetType: "Car_Component", Car_Component_ID: "Component9", Car_ID: "Car9", Fuelcell_ID: "FuelCell9", Date_added: 20200201, Last_submitted_by: "Org1MSP"}, *component)
+
+	require.EqualError(t, removeComponent("Component9", "20200131"), "the Car_Component Component9 cannot be removed before it was added on 20200201")
+	require.NoError(t, ledger.Submit(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.CreateJourney(ctx, "Journey9", "Car9", "Component9", "0", "10", "1", 0.5, "Supplier9", "20200205")
+	}))
+	// a component cannot be removed before journeys already recorded on it
+	require.EqualError(t, removeComponent("Component9", "20200205"), "the Car_Component Component9 has Journey Journey9 recorded on 20200205")
+	require.EqualError(t, removeComponent("Component9", "20200202"), "the Car_Component Component9 has Journey Journey9 recorded on 20200205")
+	require.NoError(t, removeComponent("Component9", "20200210"))
+	require.EqualError(t, removeComponent("Component9", "20200211"), "the Car_Component Component9 was already removed on 20200210")
+	require.EqualError(t, deleteComponent("Component9"), "the Car_Component Component9 still has journeys recorded against it")
+
+	// once removed the fuel cell can go to another car, and a placement nothing refers to can be deleted
+	require.NoError(t, createComponent("Component8", "Car8", "FuelCell9", "20200210"))
+	require.NoError(t, deleteComponent("Component8"))
+	err := ledger.Evaluate(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := contract.ReadCarComponent(ctx, "Component8")
+		return err
+	})
+	require.EqualError(t, err, "the Car_Component Component8 does not exist")
+}
+
+func TestSwapFuelcell(t *testing.T) {
+	ledger := lifecycleLedger(t)
+	contract := &chaincode.SmartContract{}
+	require.NoError(t, ledger.Submit(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.CreateCar(ctx, "Car8", "20191201", "")
+	}))
+	require.NoError(t, createFuelcell(ledger, supplier9Client, "FuelCell8", "Supplier9", "20200101"))
+	for _, component := range []struct{ componentID, carID, fuelcellID string }{
+		{"Component9", "Car9", "FuelCell9"},
+		{"Component8", "Car8", "FuelCell8"},
+	} {
+		require.NoError(t, ledger.Submit(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+			return contract.CreateCarComponent(ctx, component.componentID, component.carID, component.fuelcellID, "20200201")
+		}))
+	}
+	swap := func(carID string, componentID string, fuelcellID string, date string) (string, error) {
+		var newComponentID string
+		err := ledger.Submit(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			newComponentID, err = contract.SwapFuelcell(ctx, carID, componentID, fuelcellID, date)
+			return err
+		})
+		return newComponentID, err
+	}
+	fittedOn := func(fuelcellID string, date string) []string {
+		var ids []string
+		require.NoError(t, ledger.Evaluate(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+			components, err := contract.GetAllCarCompForFuelCellBetweenDates(ctx, fuelcellID, date, date)
+			for _, component := range components {
+				ids = append(ids, component.Car_Component_ID)
+			}
+			return err
+		}))
+		return ids
+	}
+
+	_, err := swap("Car8", "Component9", "FuelCell8", "20200210")
+	require.EqualError(t, err, "the Car_Component Component9 belongs to Car Car9 not Car Car8")
+	_, err = swap("Car9", "Component9", "FuelCell8", "20200131")
+	require.EqualError(t, err, "the Car_Component Component9 cannot be removed before it was added on 20200201")
+	_, err = swap("Car9", "Component9", "FuelCell8", "20200210")
+	require.EqualError(t, err, "the Fuelcell FuelCell8 is already fitted to Car Car8 as Component8 from 20200201")
+
+	// a fuel cell removed from one car on a day can be fitted to another that day, but not the day before
+	require.NoError(t, ledger.Submit(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.RemoveCarComponent(ctx, "Component8", "20200215")
+	}))
+	require.NoError(t, ledger.Submit(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.CreateJourney(ctx, "Journey8", "Car9", "Component9", "0", "10", "1", 0.5, "Supplier9", "20200212")
+	}))
+	_, err = swap("Car9", "Component9", "FuelCell8", "20200212")
+	require.EqualError(t, err, "the Car_Component Component9 has Journey Journey8 recorded on 20200212")
+	_, err = swap("Car9", "Component9", "FuelCell8", "20200214")
+	require.EqualError(t, err, "the Fuelcell FuelCell8 is already fitted to Car Car8 as Component8 from 20200201")
+	newComponentID, err := swap("Car9", "Component9", "FuelCell8", "20200215")
+	require.NoError(t, err)
+	require.Equal(t, "Car9_FuelCell8_20200215", newComponentID)
+	_, err = swap("Car9", "Component9", "FuelCell8", "20200216")
+	require.EqualError(t, err, "the Car_Component Component9 was already removed on 20200215")
+
+	// on the day of the swap each fuel cell is fitted to one car only
+	require.Equal(t, []string{"Component8"}, fittedOn("FuelCell8", "20200214"))
+	require.Equal(t, []string{"Car9_FuelCell8_20200215"}, fittedOn("FuelCell8", "20200215"))
+	require.Equal(t, []string{"Component9"}, fittedOn("FuelCell9", "20200214"))
+	require.Empty(t, fittedOn("FuelCell9", "20200215"))
+	err = ledger.Submit(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.CreateJourney(ctx, "Journey9", "Car9", "Component9", "0", "10", "1", 0.5, "Supplier9", "20200215")
+	})
+	require.EqualError(t, err, "the Car_Component Component9 was not fitted to Car Car9 on 20200215")
+	require.NoError(t, ledger.Submit(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.CreateJourney(ctx, "Journey9", "Car9", newComponentID, "10", "10", "1", 0.5, "Supplier9", "20200215")
+	}))
+}
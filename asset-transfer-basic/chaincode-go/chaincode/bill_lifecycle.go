@@ -0,0 +1,299 @@
+package chaincode
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Bill statuses. A bill is Issued by GenerateBill, then either Acknowledged and Paid by the fleet
+// operator, or Disputed and settled by the supplier issuing a credit note. Any balance left after
+// a partial credit note can still be paid. A wrong bill can be Void by the billing admin until it is
+// paid or credited, releasing its journeys to be billed again.
+const (
+	BillIssued       = "Issued"
+	BillAcknowledged = "Acknowledged"
+	BillPaid         = "Paid"
+	BillDisputed     = "Disputed"
+	BillCredited     = "Credited"
+	BillVoid         = "Void"
+)
+
+// billTransitions lists the statuses a bill may move to from each status
+var billTransitions = map[string][]string{
+	BillIssued:       {BillAcknowledged, BillDisputed, BillVoid},
+	BillAcknowledged: {BillPaid, BillDisputed, BillVoid},
+	BillDisputed:     {BillCredited, BillVoid},
+	BillCredited:     {BillPaid},
+}
+
+// CreditNote reduces the amount owed on a disputed bill
+type CreditNote struct {
+	AssetType      string `json:"AssetType"`
+	Credit_note_ID string `json:"Credit_note_ID"`
+	Bill_ID        string `json:"Bill_ID"`
+	Supplier_ID    string `json:"Supplier_ID"`
+	Currency       string `json:"Currency"`
+	Amount         Money  `json:"Amount"`
+	Reason         string `json:"Reason"`
+	Date_issued    int    `json:"Date_issued"`
+	// MSP of the client whose transaction last wrote the asset, see history.go
+	Last_submitted_by string `json:"Last_submitted_by,omitempty" metadata:",optional"`
+}
+
+// billEvent is the payload of the chaincode event emitted on every bill status change
+type billEvent struct {
+	Bill_ID         string `json:"Bill_ID"`
+	Supplier_ID     string `json:"Supplier_ID"`
+	Fuelcell_ID     string `json:"Fuelcell_ID"`
+	Previous_status string `json:"Previous_status"`
+	Status          string `json:"Status"`
+	Currency        string `json:"Currency"`
+	Amount          Money  `json:"Amount"`
+	Reason          string `json:"Reason"`
+}
+
+// ReadBill returns the bill stored in the world state with the given id. Suppliers may only read bills
+// addressed to them.
+func (s *SmartContract) ReadBill(ctx contractapi.TransactionContextInterface, billID string) (*Bill, error) {
+	bill, err := readBill(ctx, billID)
+	if err != nil {
+		return nil, err
+	}
+	err = s.assertBillReader(ctx, bill.Supplier_ID)
+	if err != nil {
+		return nil, err
+	}
+	return bill, nil
+}
+
+// readBill returns a bill without checking the client may see it
+func readBill(ctx contractapi.TransactionContextInterface, billID string) (*Bill, error) {
+	var asset Bill
+	err := readAsset(ctx, billID, "Bill", &asset)
+	if err != nil {
+		return nil, err
+	}
+	normalizeBill(&asset)
+	return &asset, nil
+}
+
+// normalizeBill fills in what bills written by earlier versions of the contract leave out
+func normalizeBill(bill *Bill) {
+	if bill.Status == "" {
+		bill.Status = BillIssued
+	}
+	if code, ok := currencyAliases[bill.Currency]; ok {
+		bill.Currency = code // written before currencies were ISO 4217 codes
+	}
+}
+
+// AcknowledgeBill is called by the fleet operator to accept an issued bill as correct
+func (s *SmartContract) AcknowledgeBill(ctx contractapi.TransactionContextInterface, billID string) error {
+	err := assertFleetOperator(ctx)
+	if err != nil {
+		return err
+	}
+	bill, err := readBill(ctx, billID)
+	if err != nil {
+		return err
+	}
+	return setBillStatus(ctx, bill, BillAcknowledged, "")
+}
+
+// DisputeBill is called by the fleet operator to refuse a bill, giving a reason for the supplier
+func (s *SmartContract) DisputeBill(ctx contractapi.TransactionContextInterface, billID string, reason string) error {
+	err := assertFleetOperator(ctx)
+	if err != nil {
+		return err
+	}
+	if reason == "" {
+		return fmt.Errorf("a reason is required to dispute bill %s", billID)
+	}
+	bill, err := readBill(ctx, billID)
+	if err != nil {
+		return err
+	}
+	return setBillStatus(ctx, bill, BillDisputed, reason)
+}
+
+// MarkBillPaid is called by the supplier to confirm it has received payment of a bill
+func (s *SmartContract) MarkBillPaid(ctx contractapi.TransactionContextInterface, billID string) error {
+	bill, err := readBill(ctx, billID)
+	if err != nil {
+		return err
+	}
+	err = s.assertSupplierClient(ctx, bill.Supplier_ID)
+	if err != nil {
+		return err
+	}
+	if bill.Status == BillCredited {
+		inFull, err := creditedInFull(ctx, bill)
+		if err != nil {
+			return err
+		}
+		if inFull {
+			return fmt.Errorf("the bill %s was credited in full so nothing is left to pay", billID)
+		}
+	}
+	return setBillStatus(ctx, bill, BillPaid, "")
+}
+
+// IssueCreditNote is called by the supplier to settle a disputed bill, crediting all or part of its amount
+func (s *SmartContract) IssueCreditNote(ctx contractapi.TransactionContextInterface, billID string, creditNoteID string, amount string, reason string) error {
+	bill, err := readBill(ctx, billID)
+	if err != nil {
+		return err
+	}
+	err = s.assertSupplierClient(ctx, bill.Supplier_ID)
+	if err != nil {
+		return err
+	}
+	if bill.Status != BillDisputed {
+		return fmt.Errorf("the bill %s is %s, only disputed bills can be credited", billID, bill.Status)
+	}
+	exists, err := assetExists(ctx, "Credit_Note", creditNoteID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("the asset %s already exists", creditNoteID)
+	}
+	credit, err := ParseMoney(amount, bill.Currency)
+	if err != nil {
+		return err
+	}
+	creditValue, err := credit.Rat()
+	if err != nil {
+		return err
+	}
+	billValue, err := bill.Amount.Rat()
+	if err != nil {
+		return err
+	}
+	if creditValue.Sign() <= 0 || creditValue.Cmp(billValue) > 0 {
+		return fmt.Errorf("the credit %s must be more than nothing and no more than the bill amount %s", credit, bill.Amount)
+	}
+	issued, err := txDate(ctx)
+	if err != nil {
+		return err
+	}
+	creditNote := CreditNote{
+		AssetType:      "Credit_Note",
+		Credit_note_ID: creditNoteID,
+		Bill_ID:        billID,
+		Supplier_ID:    bill.Supplier_ID,
+		Currency:       bill.Currency,
+		Amount:         credit,
+		Reason:         reason,
+		Date_issued:    issued,
+	}
+	err = putAsset(ctx, creditNote)
+	if err != nil {
+		return err
+	}
+	bill.Credit_note = creditNoteID
+	return setBillStatus(ctx, bill, BillCredited, reason)
+}
+
+// VoidBill is called by the billing admin to cancel a wrong bill, giving a reason. Every journey the bill
+// charged is marked as not billed, so a corrected bill for the same period can be generated; a bill without
+// line items only changes status. Bills which have been paid or credited cannot be voided.
+func (s *SmartContract) VoidBill(ctx contractapi.TransactionContextInterface, billID string, reason string) error {
+	err := assertBillingAdmin(ctx)
+	if err != nil {
+		return err
+	}
+	if reason == "" {
+		return fmt.Errorf("a reason is required to void bill %s", billID)
+	}
+	bill, err := readBill(ctx, billID)
+	if err != nil {
+		return err
+	}
+	// bills entered with CreateNewBill or generated before journeys were recorded have none to release
+	for _, journeyID := range bill.Journey_IDs {
+		journey, err := s.ReadJourney(ctx, journeyID)
+		if err != nil {
+			return err
+		}
+		journey.Billed = false
+		err = putAsset(ctx, journey)
+		if err != nil {
+			return fmt.Errorf("failed to release Journey %s from bill %s: %v", journeyID, billID, err)
+		}
+	}
+	return setBillStatus(ctx, bill, BillVoid, reason)
+}
+
+// ReadCreditNote returns the credit note stored in the world state with the given id
+func (s *SmartContract) ReadCreditNote(ctx contractapi.TransactionContextInterface, creditNoteID string) (*CreditNote, error) {
+	var asset CreditNote
+	err := readAsset(ctx, creditNoteID, "Credit_Note", &asset)
+	if err != nil {
+		return nil, err
+	}
+	err = s.assertBillReader(ctx, asset.Supplier_ID)
+	if err != nil {
+		return nil, err
+	}
+	return &asset, nil
+}
+
+// billSettled reports whether nothing more can be owed on a bill: it has been paid, voided or
+// credited in full
+func billSettled(ctx contractapi.TransactionContextInterface, bill *Bill) (bool, error) {
+	switch bill.Status {
+	case BillPaid, BillVoid:
+		return true, nil
+	case BillCredited:
+		return creditedInFull(ctx, bill)
+	}
+	return false, nil
+}
+
+// creditedInFull reports whether the credit note issued against a credited bill covers its whole amount
+func creditedInFull(ctx contractapi.TransactionContextInterface, bill *Bill) (bool, error) {
+	var creditNote CreditNote
+	err := readAsset(ctx, bill.Credit_note, "Credit_Note", &creditNote)
+	if err != nil {
+		return false, err
+	}
+	credited, err := creditNote.Amount.Rat()
+	if err != nil {
+		return false, err
+	}
+	owed, err := bill.Amount.Rat()
+	if err != nil {
+		return false, err
+	}
+	return credited.Cmp(owed) == 0, nil
+}
+
+// setBillStatus moves a bill to a new status if the state machine allows it, saves it and emits an event
+func setBillStatus(ctx contractapi.TransactionContextInterface, bill *Bill, status string, reason string) error {
+	allowed := false
+	for _, next := range billTransitions[bill.Status] {
+		if next == status {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return fmt.Errorf("the bill %s cannot move from %s to %s", bill.Bill_ID, bill.Status, status)
+	}
+	date, err := txDate(ctx)
+	if err != nil {
+		return err
+	}
+	previous := bill.Status
+	bill.Status = status
+	bill.Status_date = date
+	if reason != "" {
+		bill.Reason = reason
+	}
+	err = putAsset(ctx, bill)
+	if err != nil {
+		return err
+	}
+	return emitEvent(ctx, "Bill"+status, newBillEvent(bill, previous, reason))
+}
@@ -0,0 +1,545 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Line item types
+const (
+	LineJourney  = "Journey"   // distance and energy charges for one journey
+	LineBaseDays = "Base_days" // the base rate for a run of days held at the same tariff
+)
+
+// BillLineItem is one charge making up a bill. Each monetary field is rounded on its own using the
+// currency's policy and Amount is their sum, so the line items of a bill always add up to its Amount.
+// Line items reveal the rates they were charged at, so they are held with the rates in the tariff
+// collection and only the bill's Amount is public.
+type BillLineItem struct {
+	Item_type        string  `json:"Item_type"`        // Journey or Base_days
+	Journey_ID       string  `json:"Journey_ID"`       // empty for base days
+	Car_Component_ID string  `json:"Car_Component_ID"` // empty for base days
+	Date_from        int     `json:"Date_from"`        // journey date, or first day charged at the base rate
+	Date_to          int     `json:"Date_to"`          // journey date, or last day charged at the base rate
+	Days             int     `json:"Days"`
+	Distance         int     `json:"Distance"`
+	H2_used          int     `json:"H2_used"`
+	Efficiency       float32 `json:"Efficiency"`
+	Tariff_ID        string  `json:"Tariff_ID"` // the tariff charged, whose rates are held privately
+	Base_cost        Money   `json:"Base_cost"`
+	Distance_cost    Money   `json:"Distance_cost"`
+	Energy_cost      Money   `json:"Energy_cost"`
+	Amount           Money   `json:"Amount"`
+}
+
+// BillLines are the line items of a bill, held in the tariff collection under the composite key of
+// Bill_Lines and the Bill_ID
+type BillLines struct {
+	AssetType  string         `json:"AssetType"`
+	Bill_ID    string         `json:"Bill_ID"`
+	Currency   string         `json:"Currency"`
+	Line_items []BillLineItem `json:"Line_items"`
+	Salt       string         `json:"Salt"` // derived from the salts of the tariffs charged, so the hash cannot be matched by guessing rates
+}
+
+// BillBreakdown explains how the amount of a bill was made up
+type BillBreakdown struct {
+	Bill_ID     string         `json:"Bill_ID"`
+	Fuelcell_ID string         `json:"Fuelcell_ID"`
+	Date_from   int            `json:"Date_from"`
+	Date_to     int            `json:"Date_to"`
+	Currency    string         `json:"Currency"`
+	Amount      Money          `json:"Amount"`
+	Line_items  []BillLineItem `json:"Line_items"`
+}
+
+// maxBillingRunPage caps the fuel cells billed by one GenerateBillsForPeriod call so that a single
+// transaction stays well inside the peer's endorsement and message size limits
+const maxBillingRunPage = 25
+
+// BillingRunResult reports one page of a GenerateBillsForPeriod run
+type BillingRunResult struct {
+	Bills    []*Bill  `json:"Bills"`    // bills issued by this call
+	Skipped  []string `json:"Skipped"`  // fuel cells already billed for the period by an earlier run
+	Failed   []string `json:"Failed"`   // fuel cells which could not be billed, with the reason
+	Bookmark string   `json:"Bookmark"` // pass to the next call, empty once every fuel cell has been covered
+}
+
+// BillPreview is the bill GenerateBill would issue, with anything in the data worth checking first
+type BillPreview struct {
+	Bill       *Bill          `json:"Bill"`
+	Line_items []BillLineItem `json:"Line_items"`
+	Warnings   []string       `json:"Warnings"`
+}
+
+// PreviewBill is an evaluate only query returning the bill GenerateBill would issue for the fuel cell
+// and period, including its line items, without writing the bill or marking any journey as billed
+func (s *SmartContract) PreviewBill(ctx contractapi.TransactionContextInterface, fuelcellID string, startDate string, endDate string) (*BillPreview, error) {
+	err := assertBillingAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	bill, lines, _, err := s.prepareBill(ctx, fuelcellID, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	billingPeriod, err := newPeriod(startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	warnings, err := s.billWarnings(ctx, fuelcellID, billingPeriod)
+	if err != nil {
+		return nil, err
+	}
+	return &BillPreview{Bill: bill, Line_items: lines.Line_items, Warnings: warnings}, nil
+}
+
+// GenerateBillsForPeriod issues one bill for each fuel cell held during the period, a page of fuel cells
+// at a time in Fuelcell_ID order. Call it again with the returned bookmark until the bookmark comes back
+// empty. Bill IDs are derived from the fuel cell and period, so re-running a page skips the fuel cells
+// it has already billed instead of billing them twice. A fuel cell whose bill for the period was voided
+// is billed again under the next revision of the ID, suffixed _r1, _r2 and so on, and only skipped once
+// a bill that is not void is held. Fuel cells which cannot be priced are listed in Failed and the rest of
+// the page is still billed, but a bill which fails to be written fails the page.
+func (s *SmartContract) GenerateBillsForPeriod(ctx contractapi.TransactionContextInterface, startDate string, endDate string, pageSize int, bookmark string) (*BillingRunResult, error) {
+	err := assertBillingAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if pageSize < 1 || pageSize > maxBillingRunPage {
+		return nil, fmt.Errorf("the page size must be between 1 and %d", maxBillingRunPage)
+	}
+	billingPeriod, err := newPeriod(startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	fuelcells, err := s.GetAllSuppliersFuelCellsBetweenDates(ctx, "", startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(fuelcells, func(i, j int) bool {
+		return fuelcells[i].Fuelcell_ID < fuelcells[j].Fuelcell_ID
+	})
+
+	result := BillingRunResult{Bills: []*Bill{}, Skipped: []string{}, Failed: []string{}}
+	processed := 0
+	for _, fuelcell := range fuelcells {
+		if fuelcell.Fuelcell_ID <= bookmark {
+			continue // covered by an earlier page
+		}
+		if processed == pageSize {
+			return billingRunPage(ctx, &result, billingPeriod)
+		}
+		processed++
+		result.Bookmark = fuelcell.Fuelcell_ID
+
+		billID, issued, err := periodBillRevision(ctx, fuelcell.Fuelcell_ID, billingPeriod)
+		if err != nil {
+			return nil, err
+		}
+		if issued {
+			result.Skipped = append(result.Skipped, fuelcell.Fuelcell_ID)
+			continue
+		}
+		bill, lines, billedJourneys, err := s.prepareBill(ctx, fuelcell.Fuelcell_ID, startDate, endDate)
+		if err != nil {
+			// pricing writes nothing, so the rest of the page can still be billed
+			result.Failed = append(result.Failed, fmt.Sprintf("%s: %v", fuelcell.Fuelcell_ID, err))
+			continue
+		}
+		bill.Bill_ID = billID
+		err = s.writeBill(ctx, bill, lines, billedJourneys)
+		if err != nil {
+			// the bill may be written without its journeys marked, so the whole page must fail
+			return nil, fmt.Errorf("failed to issue bill %s: %v", billID, err)
+		}
+		result.Bills = append(result.Bills, bill)
+	}
+	result.Bookmark = "" // every fuel cell has been covered
+	return billingRunPage(ctx, &result, billingPeriod)
+}
+
+// billingRunPage finishes a page of a billing run, replacing the event of the last bill issued with
+// one listing every bill issued by the page
+func billingRunPage(ctx contractapi.TransactionContextInterface, result *BillingRunResult, billingPeriod period) (*BillingRunResult, error) {
+	if len(result.Bills) == 0 {
+		return result, nil
+	}
+	event := billingRunEvent{
+		Date_from: ledgerDate(billingPeriod.from),
+		Date_to:   ledgerDate(billingPeriod.to),
+		Bills:     []billEvent{},
+	}
+	for _, bill := range result.Bills {
+		event.Bills = append(event.Bills, newBillEvent(bill, "", ""))
+	}
+	err := emitEvent(ctx, EventBillsIssued, event)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// periodBillID is the ID of the bill GenerateBillsForPeriod issues for a fuel cell and period
+func periodBillID(fuelcellID string, billingPeriod period) string {
+	return fmt.Sprintf("%s_%d_%d", fuelcellID, ledgerDate(billingPeriod.from), ledgerDate(billingPeriod.to))
+}
+
+// periodBillRevision returns the ID under which the period's bill for a fuel cell is held, or is to be
+// issued, and whether a bill that is not void is already held there. Each voided bill is superseded by
+// the next revision of the period's ID, eg FuelCell1_20210601_20210630_r1 after FuelCell1_20210601_20210630.
+func periodBillRevision(ctx contractapi.TransactionContextInterface, fuelcellID string, billingPeriod period) (string, bool, error) {
+	baseID := periodBillID(fuelcellID, billingPeriod)
+	billID := baseID
+	for revision := 1; ; revision++ {
+		exists, err := assetExists(ctx, "Bill", billID)
+		if err != nil {
+			return "", false, err
+		}
+		if !exists {
+			return billID, false, nil
+		}
+		existing, err := readBill(ctx, billID)
+		if err != nil {
+			return "", false, err
+		}
+		if existing.Status != BillVoid {
+			return billID, true, nil
+		}
+		billID = fmt.Sprintf("%s_r%d", baseID, revision)
+	}
+}
+
+// GetBillBreakdown returns the line items of a bill from the tariff collection, so it must be called on a
+// peer of an organisation in the collection. Each line names the tariff it was charged at, whose rates
+// can be read with ReadTariffRates; a billed tariff can never be changed, so the breakdown stays correct.
+func (s *SmartContract) GetBillBreakdown(ctx contractapi.TransactionContextInterface, billID string) (*BillBreakdown, error) {
+	bill, err := s.ReadBill(ctx, billID)
+	if err != nil {
+		return nil, err
+	}
+	if bill.Line_items_hash == "" { // bills entered with CreateNewBill or generated before line items were kept
+		return nil, fmt.Errorf("the bill %s was created without line items", billID)
+	}
+	lines, err := readBillLines(ctx, bill)
+	if err != nil {
+		return nil, err
+	}
+	return &BillBreakdown{
+		Bill_ID:     bill.Bill_ID,
+		Fuelcell_ID: bill.Fuelcell_ID,
+		Date_from:   bill.Date_from,
+		Date_to:     bill.Date_to,
+		Currency:    bill.Currency,
+		Amount:      bill.Amount,
+		Line_items:  lines.Line_items,
+	}, nil
+}
+
+// putBillLines writes the line items of a bill to the tariff collection, setting their hash on the bill
+func putBillLines(ctx contractapi.TransactionContextInterface, bill *Bill, lines *BillLines) error {
+	lines.AssetType = "Bill_Lines"
+	lines.Bill_ID = bill.Bill_ID
+	err := lines.validate()
+	if err != nil {
+		return err
+	}
+	linesJSON, err := json.Marshal(lines)
+	if err != nil {
+		return err
+	}
+	key, err := assetKey(ctx, lines.AssetType, lines.Bill_ID)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().PutPrivateData(tariffCollection, key, linesJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put line items into private data collection: %v", err)
+	}
+	hash := sha256.Sum256(linesJSON)
+	bill.Line_items_hash = hex.EncodeToString(hash[:])
+	return nil
+}
+
+// readBillLines reads the line items of a bill from the tariff collection, checking they match the hash
+// on the bill
+func readBillLines(ctx contractapi.TransactionContextInterface, bill *Bill) (*BillLines, error) {
+	key, err := assetKey(ctx, "Bill_Lines", bill.Bill_ID)
+	if err != nil {
+		return nil, err
+	}
+	linesJSON, err := ctx.GetStub().GetPrivateData(tariffCollection, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read line items from private data collection: %v", err)
+	}
+	if linesJSON == nil {
+		return nil, fmt.Errorf("the line items of bill %s are not held by this peer", bill.Bill_ID)
+	}
+	hash := sha256.Sum256(linesJSON)
+	if hex.EncodeToString(hash[:]) != bill.Line_items_hash {
+		return nil, fmt.Errorf("the line items of bill %s do not match the hash on the ledger", bill.Bill_ID)
+	}
+	var lines BillLines
+	err = json.Unmarshal(linesJSON, &lines)
+	if err != nil {
+		return nil, err
+	}
+	return &lines, nil
+}
+
+// prepareBill checks a fuel cell can be billed for the period, refusing periods that overlap an earlier
+// bill for the same fuel cell, and prices the bill. Nothing is written.
+func (s *SmartContract) prepareBill(ctx contractapi.TransactionContextInterface, fuelcellID string, startDate string, endDate string) (*Bill, *BillLines, []*JourneyData, error) {
+	PastBills, err := billsMatching(ctx, selector{"AssetType": "Bill", "Fuelcell_ID": fuelcellID})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	Fuelcell, err := s.GetFuelcell(ctx, fuelcellID)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if Fuelcell == nil {
+		return nil, nil, nil, fmt.Errorf("the Fuelcell %s does not exist", fuelcellID)
+	}
+	billingPeriod, err := newPeriod(startDate, endDate)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for _, PastBill := range PastBills {
+		if PastBill.Status == BillVoid {
+			continue // its journeys have been released to be billed again
+		}
+		if PastBill.Fuelcell_ID == Fuelcell.Fuelcell_ID {
+			pastPeriod, err := ledgerPeriod(PastBill.Date_from, PastBill.Date_to)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			if billingPeriod.overlaps(pastPeriod) {
+				return nil, nil, nil, fmt.Errorf("this bill would overlap the time frame %d-%d covered by bill %s", PastBill.Date_from, PastBill.Date_to, PastBill.Bill_ID)
+			}
+		}
+	}
+	return s.priceBill(ctx, Fuelcell, billingPeriod)
+}
+
+// priceBill works out what a fuel cell owes for a billing period: the base rate for each day it was held
+// plus the distance and energy charges for every journey not yet billed, each at the tariff in force
+// on the day. It writes nothing; the returned
+// bill has no ID, the returned lines are its private line items and the returned journeys are those
+// it charged for.
+func (s *SmartContract) priceBill(ctx contractapi.TransactionContextInterface, fuelcell *FuelcellData, billingPeriod period) (*Bill, *BillLines, []*JourneyData, error) {
+	currency, policy, err := currencyCode(fuelcell.Currency)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	heldPeriod, err := ledgerPeriod(fuelcell.Date_Received, fuelcell.Date_Returned)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if heldPeriod.from.After(billingPeriod.to) { // if fuelcell was recieved after the end date
+		return nil, nil, nil, fmt.Errorf("fuelcell %s didn't exist in this time frame", fuelcell.Fuelcell_ID)
+	}
+	if heldPeriod.to.Before(billingPeriod.from) { // if the fuel cell has been returned and was returned before the start date
+		return nil, nil, nil, fmt.Errorf("fuelcell %s had been returned before this time frame", fuelcell.Fuelcell_ID)
+	}
+
+	var lineItems []BillLineItem
+	var total int64 // in minor units of the currency
+
+	// only the days the fuel cell was held attract the base rate, at the rate in force on each day
+	chargedPeriod, _ := billingPeriod.intersect(heldPeriod)
+	schedule, err := tariffSchedule(ctx, fuelcell)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	// tariffs run on from the first without gaps, so every held day has one if the first held day does
+	_, err = tariffOn(schedule, ledgerDate(chargedPeriod.from))
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("cannot price Fuelcell %s: %v", fuelcell.Fuelcell_ID, err)
+	}
+	for _, entry := range schedule {
+		subPeriod, ok := chargedPeriod.intersect(entry.inForce)
+		if !ok {
+			continue
+		}
+		baseRate, err := entry.rates.Base_rate.Rat()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		baseCost, err := policy.roundToMinor(new(big.Rat).Mul(big.NewRat(int64(subPeriod.days()), 1), baseRate))
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("cannot price Fuelcell %s: %v", fuelcell.Fuelcell_ID, err)
+		}
+		lineItems = append(lineItems, BillLineItem{
+			Item_type: LineBaseDays,
+			Date_from: ledgerDate(subPeriod.from),
+			Date_to:   ledgerDate(subPeriod.to),
+			Days:      subPeriod.days(),
+			Tariff_ID: entry.tariff.Tariff_ID,
+			Base_cost: moneyFromMinor(baseCost, policy),
+			Amount:    moneyFromMinor(baseCost, policy),
+		})
+		total, err = addMinor(total, baseCost)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("cannot price Fuelcell %s: %v", fuelcell.Fuelcell_ID, err)
+		}
+	}
+
+	// query to get carCompenents relevent, may return multiple if fuelcell moved inside billing time
+	startDate := billingPeriod.from.Format(ledgerDateLayout)
+	endDate := billingPeriod.to.Format(ledgerDateLayout)
+	releventCarComponents, err := s.GetAllCarCompForFuelCellBetweenDates(ctx, fuelcell.Fuelcell_ID, startDate, endDate)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	// find relevent journey where car_Component is correct and journey is in range of car component installed
+	var billedJourneys []*JourneyData
+	journeyIDs := []string{}
+	for _, currentCarComponent := range releventCarComponents {
+		releventJourneys, err := s.GetAllJourneysbetweendatesforCarComponent(ctx, currentCarComponent.Car_Component_ID, startDate, endDate)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		for _, currentJourney := range releventJourneys {
+			if currentJourney.Billed {
+				continue
+			}
+			tariff, err := tariffOn(schedule, currentJourney.Journey_date)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("cannot price Journey %s: %v", currentJourney.Journey_ID, err)
+			}
+			distanceCharge, energyCharge, err := journeyCharges(currentJourney, tariff.rates.Distance_rate, tariff.rates.Energy_rate)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			distanceCost, err := policy.roundToMinor(distanceCharge)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("cannot price Journey %s: %v", currentJourney.Journey_ID, err)
+			}
+			energyCost, err := policy.roundToMinor(energyCharge)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("cannot price Journey %s: %v", currentJourney.Journey_ID, err)
+			}
+			journeyCost, err := addMinor(distanceCost, energyCost)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("cannot price Journey %s: %v", currentJourney.Journey_ID, err)
+			}
+			lineItems = append(lineItems, BillLineItem{
+				Item_type:        LineJourney,
+				Journey_ID:       currentJourney.Journey_ID,
+				Car_Component_ID: currentJourney.Car_Component_ID,
+				Date_from:        currentJourney.Journey_date,
+				Date_to:          currentJourney.Journey_date,
+				Distance:         currentJourney.Distance,
+				H2_used:          currentJourney.H2_used,
+				Efficiency:       currentJourney.Efficiency,
+				Tariff_ID:        tariff.tariff.Tariff_ID,
+				Distance_cost:    moneyFromMinor(distanceCost, policy),
+				Energy_cost:      moneyFromMinor(energyCost, policy),
+				Amount:           moneyFromMinor(journeyCost, policy),
+			})
+			total, err = addMinor(total, journeyCost)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("cannot price Fuelcell %s: %v", fuelcell.Fuelcell_ID, err)
+			}
+			billedJourneys = append(billedJourneys, currentJourney)
+			journeyIDs = append(journeyIDs, currentJourney.Journey_ID)
+		}
+	}
+
+	bill := Bill{
+		AssetType:   "Bill",
+		Supplier_ID: fuelcell.Supplier_ID,
+		Fuelcell_ID: fuelcell.Fuelcell_ID,
+		Date_from:   ledgerDate(billingPeriod.from),
+		Date_to:     ledgerDate(billingPeriod.to),
+		Currency:    currency,
+		Amount:      moneyFromMinor(total, policy),
+		Journey_IDs: journeyIDs,
+	}
+	// the salts of the tariffs are private, so the hash of the line items cannot be matched by guessing rates
+	salt := sha256.New()
+	for _, entry := range schedule {
+		salt.Write([]byte(entry.rates.Salt))
+	}
+	lines := BillLines{Currency: currency, Line_items: lineItems, Salt: hex.EncodeToString(salt.Sum(nil))}
+	return &bill, &lines, billedJourneys, nil
+}
+
+// billWarnings looks for data behind a bill that should be checked before it is issued: journeys dated
+// outside the placement of the component they were recorded against, journeys already billed, and
+// gaps between one journey's end and the next journey's start on the odometer of a car
+func (s *SmartContract) billWarnings(ctx contractapi.TransactionContextInterface, fuelcellID string, billingPeriod period) ([]string, error) {
+	warnings := []string{}
+	startDate := billingPeriod.from.Format(ledgerDateLayout)
+	endDate := billingPeriod.to.Format(ledgerDateLayout)
+	components, err := s.GetAllCarCompForFuelCellBetweenDates(ctx, fuelcellID, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	var carIDs []string
+	seenCars := make(map[string]bool)
+	for _, component := range components {
+		if !seenCars[component.Car_ID] {
+			seenCars[component.Car_ID] = true
+			carIDs = append(carIDs, component.Car_ID)
+		}
+		placement, _, err := placementPeriod(component)
+		if err != nil {
+			return nil, err
+		}
+		journeys, err := s.GetAllJourneysbetweendatesforCarComponent(ctx, component.Car_Component_ID, startDate, endDate)
+		if err != nil {
+			return nil, err
+		}
+		for _, journey := range journeys {
+			journeyDate, err := dateFromLedger(journey.Journey_date)
+			if err != nil {
+				return nil, err
+			}
+			if !placement.contains(journeyDate) {
+				warnings = append(warnings, fmt.Sprintf("Journey %s on %d is outside the placement of %s from %d to %d",
+					journey.Journey_ID, journey.Journey_date, component.Car_Component_ID, component.Date_added, component.Date_removed))
+			}
+			if journey.Billed {
+				warnings = append(warnings, fmt.Sprintf("Journey %s on %d has already been billed and is not charged again", journey.Journey_ID, journey.Journey_date))
+			}
+		}
+	}
+
+	// odometer gaps are checked across every journey of each car, whichever fuel cell it used
+	for _, carID := range carIDs {
+		journeys, err := s.GetAllJourneysofCar(ctx, carID)
+		if err != nil {
+			return nil, err
+		}
+		sort.SliceStable(journeys, func(i, j int) bool {
+			if journeys[i].Journey_date != journeys[j].Journey_date {
+				return journeys[i].Journey_date < journeys[j].Journey_date
+			}
+			return journeys[i].Odo_start < journeys[j].Odo_start
+		})
+		for i := 1; i < len(journeys); i++ {
+			previous, current := journeys[i-1], journeys[i]
+			currentDate, err := dateFromLedger(current.Journey_date)
+			if err != nil {
+				return nil, err
+			}
+			if !billingPeriod.contains(currentDate) {
+				continue
+			}
+			previousEnd := previous.Odo_start + previous.Distance
+			if current.Odo_start > previousEnd {
+				warnings = append(warnings, fmt.Sprintf("Car %s has %d unrecorded distance units between Journey %s and Journey %s",
+					carID, current.Odo_start-previousEnd, previous.Journey_ID, current.Journey_ID))
+			}
+		}
+	}
+	return warnings, nil
+}
@@ -0,0 +1,210 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/simulator"
+	"github.com/stretchr/testify/require"
+)
+
+// runBilling submits one page of a billing run for June 2021, when all three fuel cells of InitLedger
+// are held
+func runBilling(ledger *simulator.Ledger, pageSize int, bookmark string) (*chaincode.BillingRunResult, error) {
+	var result *chaincode.BillingRunResult
+	err := ledger.Submit(adminClient, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		result, err = (&chaincode.SmartContract{}).GenerateBillsForPeriod(ctx, "20210601", "20210630", pageSize, bookmark)
+		return err
+	})
+	return result, err
+}
+
+func billIDs(bills []*chaincode.Bill) []string {
+	ids := []string{}
+	for _, bill := range bills {
+		ids = append(ids, bill.Bill_ID)
+	}
+	return ids
+}
+
+func TestGenerateBillsForPeriodPages(t *testing.T) {
+	ledger := simulator.NewLedger(time.Date(2021, time.July, 1, 9, 0, 0, 0, time.UTC))
+	require.NoError(t, ledger.Submit(adminClient, (&chaincode.SmartContract{}).InitLedger))
+
+	_, err := runBilling(ledger, 0, "")
+	require.EqualError(t, err, "the page size must be between 1 and 25")
+	_, err = runBilling(ledger, 26, "")
+	require.EqualError(t, err, "the page size must be between 1 and 25")
+
+	result, err := runBilling(ledger, 2, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"FuelCell1_20210601_20210630", "FuelCell2_20210601_20210630"}, billIDs(result.Bills))
+	require.Equal(t, "FuelCell2", result.Bookmark)
+	events := ledger.Events()
+	require.Equal(t, chaincode.EventBillsIssued, events[len(events)-1].EventName)
+
+	result, err = runBilling(ledger, 2, result.Bookmark)
+	require.NoError(t, err)
+	require.Equal(t, []string{"FuelCell3_20210601_20210630"}, billIDs(result.Bills))
+	require.Empty(t, result.Bookmark, "the last page returns no bookmark")
+
+	// re-running the run finds every bill already issued under the same IDs
+	result, err = runBilling(ledger, 25, "")
+	require.NoError(t, err)
+	require.Empty(t, result.Bills)
+	require.Equal(t, []string{"FuelCell1", "FuelCell2", "FuelCell3"}, result.Skipped)
+	require.Empty(t, result.Failed)
+	require.Empty(t, result.Bookmark)
+}
+
+func TestGenerateBillsForPeriodFailures(t *testing.T) {
+	ledger := simulator.NewLedger(time.Date(2021, time.July, 1, 9, 0, 0, 0, time.UTC))
+	contract := &chaincode.SmartContract{}
+	require.NoError(t, ledger.Submit(adminClient, contract.InitLedger))
+	require.NoError(t, ledger.Submit(adminClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.GenerateBill(ctx, "Bill1", "FuelCell2", "20210615", "20210715")
+	}))
+	require.NoError(t, ledger.Submit(adminClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.GenerateBill(ctx, "FuelCell3_20210601_20210630", "FuelCell3", "20210601", "20210630")
+	}))
+	require.NoError(t, ledger.Submit(adminClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.VoidBill(ctx, "FuelCell3_20210601_20210630", "wrong tariff")
+	}))
+
+	// a fuel cell which cannot be priced is reported and the rest of the page is still billed, and a voided
+	// bill is superseded by the next revision of its ID
+	result, err := runBilling(ledger, 25, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"FuelCell1_20210601_20210630", "FuelCell3_20210601_20210630_r1"}, billIDs(result.Bills))
+	require.Equal(t, []string{
+		"FuelCell2: this bill would overlap the time frame 20210615-20210715 covered by bill Bill1",
+	}, result.Failed)
+	require.Nil(t, ledger.State(compositeKey(t, "Bill", "FuelCell2_20210601_20210630")))
+
+	// once the revision is voided too the next one supersedes it, and a held revision is skipped
+	require.NoError(t, ledger.Submit(adminClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.VoidBill(ctx, "FuelCell3_20210601_20210630_r1", "wrong tariff again")
+	}))
+	result, err = runBilling(ledger, 25, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"FuelCell3_20210601_20210630_r2"}, billIDs(result.Bills))
+	require.Equal(t, []string{"FuelCell1"}, result.Skipped)
+	result, err = runBilling(ledger, 25, "")
+	require.NoError(t, err)
+	require.Empty(t, result.Bills)
+	require.Equal(t, []string{"FuelCell1", "FuelCell3"}, result.Skipped)
+}
+
+func TestGenerateBillsForPeriodAbortsOnWriteFailure(t *testing.T) {
+	ledger := simulator.NewLedger(time.Date(2021, time.July, 1, 9, 0, 0, 0, time.UTC))
+	require.NoError(t, ledger.Submit(adminClient, (&chaincode.SmartContract{}).InitLedger))
+	// a journey written before efficiencies were checked can be priced but not saved again as billed
+	legacy, err := json.Marshal(chaincode.JourneyData{AssetType: "Journey", Journey_ID: "Journey9", Car_ID: "Car3", Car_Component_ID: "Component3",
+		Odo_start: 500, Distance: 100, H2_used: 10, Efficiency: 1.5, Journey_date: 20210628})
+	require.NoError(t, err)
+	require.NoError(t, ledger.Submit(adminClient, func(ctx contractapi.TransactionContextInterface) error {
+		return ctx.GetStub().PutState(compositeKey(t, "Journey", "Journey9"), legacy)
+	}))
+	require.NoError(t, ledger.Submit(adminClient, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := (&chaincode.SmartContract{}).RebuildJourneyIndexes(ctx)
+		return err
+	}))
+
+	_, err = runBilling(ledger, 25, "")
+	require.EqualError(t, err, "failed to issue bill FuelCell2_20210601_20210630: failed to mark Journey Journey9 as billed error: invalid Journey Journey9: [{\"Field\":\"Efficiency\",\"Message\":\"must be between 0 and 1\"}]")
+	for _, billID := range []string{"FuelCell1_20210601_20210630", "FuelCell2_20210601_20210630"} {
+		require.Nil(t, ledger.State(compositeKey(t, "Bill", billID)), billID)
+	}
+	var journey chaincode.JourneyData
+	require.NoError(t, json.Unmarshal(ledger.State(compositeKey(t, "Journey", "Journey4")), &journey))
+	require.False(t, journey.Billed)
+}
+
+func TestPreviewBill(t *testing.T) {
+	ledger := simulator.NewLedger(time.Date(2020, time.March, 2, 9, 0, 0, 0, time.UTC))
+	contract := &chaincode.SmartContract{}
+	require.NoError(t, ledger.Submit(adminClient, contract.InitLedger))
+	preview := func(client *simulator.Client) (*chaincode.BillPreview, error) {
+		var result *chaincode.BillPreview
+		err := ledger.Submit(client, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			result, err = contract.PreviewBill(ctx, "FuelCell1", "20200101", "20200229")
+			return err
+		})
+		return result, err
+	}
+
+	_, err := preview(fleetClient)
+	require.Error(t, err, "only the billing admin may preview bills")
+
+	// even when submitted, a preview writes nothing
+	keys := ledger.Keys()
+	events := len(ledger.Events())
+	result, err := preview(adminClient)
+	require.NoError(t, err)
+	require.Equal(t, keys, ledger.Keys())
+	require.Len(t, ledger.Events(), events)
+	for _, journeyID := range []string{"Journey1", "Journey2"} {
+		var journey chaincode.JourneyData
+		require.NoError(t, json.Unmarshal(ledger.State(compositeKey(t, "Journey", journeyID)), &journey))
+		require.False(t, journey.Billed, journeyID)
+	}
+	require.Empty(t, result.Bill.Bill_ID)
+	require.Empty(t, result.Warnings)
+
+	// the bill then issued for the period matches the preview
+	require.NoError(t, ledger.Submit(adminClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.GenerateBill(ctx, "Bill1", "FuelCell1", "20200101", "20200229")
+	}))
+	bill := readBill(t, ledger, "Bill1")
+	require.Equal(t, bill.Amount, result.Bill.Amount)
+	require.Equal(t, bill.Currency, result.Bill.Currency)
+	require.Equal(t, bill.Journey_IDs, result.Bill.Journey_IDs)
+	var breakdown *chaincode.BillBreakdown
+	require.NoError(t, ledger.Evaluate(adminClient, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		breakdown, err = contract.GetBillBreakdown(ctx, "Bill1")
+		return err
+	}))
+	require.Equal(t, breakdown.Line_items, result.Line_items)
+
+	// once issued, a preview of the same period is refused as it would overlap
+	_, err = preview(adminClient)
+	require.EqualError(t, err, "this bill would overlap the time frame 20200101-20200229 covered by bill Bill1")
+}
+
+func TestPreviewBillRejectsAmountsTooLarge(t *testing.T) {
+	ledger := simulator.NewLedger(time.Date(2020, time.January, 1, 9, 0, 0, 0, time.UTC))
+	contract := &chaincode.SmartContract{}
+	require.NoError(t, ledger.Submit(adminClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.CreateSupplier(ctx, "Supplier9", "Hydrogen9", "Org2MSP", "")
+	}))
+	// a day at this base rate is 5e18 pence, just inside an int64, so two days are too large to bill
+	rates := map[string][]byte{
+		"tariff_rates": []byte(`{"Base_rate":"50000000000000000","Distance_rate":"0","Energy_rate":"0","Salt":"overflow test salt"}`),
+	}
+	ctx, stub := ledger.NewContext(supplier9Client)
+	stub.SetTransient(rates)
+	require.NoError(t, contract.CreateFuelcell(ctx, "FuelCell9", "Supplier9", "GBP", "20200101", ""))
+	require.NoError(t, stub.Commit())
+	ctx, stub = ledger.NewContext(supplier9Client)
+	stub.SetTransient(rates)
+	require.NoError(t, contract.SetFuelcellTariff(ctx, "FuelCell9", "20200102"))
+	require.NoError(t, stub.Commit())
+
+	preview := func(endDate string) error {
+		return ledger.Evaluate(adminClient, func(ctx contractapi.TransactionContextInterface) error {
+			_, err := contract.PreviewBill(ctx, "FuelCell9", "20200101", endDate)
+			return err
+		})
+	}
+	require.NoError(t, preview("20200101"))
+	// each tariff's day fits, but not their sum
+	require.EqualError(t, preview("20200102"), "cannot price Fuelcell FuelCell9: the amount is too large")
+	// nor do two days at the second tariff
+	require.EqualError(t, preview("20200103"), "cannot price Fuelcell FuelCell9: the amount 100000000000000000.00 is too large")
+}
@@ -0,0 +1,134 @@
+package chaincode
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Calendar handling for billing. Dates are stored on the ledger as YYYYMMDD integers, with 0 meaning
+// "not yet" for removal and return dates, but all arithmetic is done on real calendar days so that
+// 20200131 to 20200201 is two days rather than seventy one. Transactions accept either the stored
+// YYYYMMDD form or ISO 8601 (2020-01-31 or a full RFC 3339 timestamp).
+
+const ledgerDateLayout = "20060102"
+
+// openEnded stands in for a Date_removed or Date_Returned of 0
+var openEnded = time.Date(9999, time.December, 31, 0, 0, 0, 0, time.UTC)
+
+// period is an inclusive range of calendar days
+type period struct {
+	from time.Time
+	to   time.Time
+}
+
+// parseDate reads a calendar date given as YYYYMMDD, YYYY-MM-DD or an RFC 3339 timestamp
+func parseDate(s string) (time.Time, error) {
+	for _, layout := range []string{ledgerDateLayout, "2006-01-02"} {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t, nil
+		}
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q: expected YYYYMMDD or ISO 8601", s)
+	}
+	// keep the calendar day as written rather than the UTC day it falls on
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC), nil
+}
+
+// parseLedgerDate reads a date argument and returns it in the YYYYMMDD form stored on the ledger
+func parseLedgerDate(s string) (int, error) {
+	t, err := parseDate(s)
+	if err != nil {
+		return 0, err
+	}
+	return ledgerDate(t), nil
+}
+
+// ledgerDate converts a calendar date to the YYYYMMDD integer stored on the ledger
+func ledgerDate(t time.Time) int {
+	return t.Year()*10000 + int(t.Month())*100 + t.Day()
+}
+
+// txDate returns the calendar day of the transaction timestamp, which every endorser agrees on
+func txDate(ctx contractapi.TransactionContextInterface) (int, error) {
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+	return ledgerDate(timestamp.AsTime()), nil
+}
+
+// dateFromLedger converts a stored YYYYMMDD integer back to a calendar date
+func dateFromLedger(d int) (time.Time, error) {
+	t, err := time.Parse(ledgerDateLayout, fmt.Sprintf("%08d", d))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid stored date %d", d)
+	}
+	return t, nil
+}
+
+// newPeriod builds a period from two arguments, checking the end is not before the start
+func newPeriod(startDate string, endDate string) (period, error) {
+	from, err := parseDate(startDate)
+	if err != nil {
+		return period{}, err
+	}
+	to, err := parseDate(endDate)
+	if err != nil {
+		return period{}, err
+	}
+	if to.Before(from) {
+		return period{}, fmt.Errorf("the end date %s is before the start date %s", endDate, startDate)
+	}
+	return period{from: from, to: to}, nil
+}
+
+// ledgerPeriod builds a period from stored dates where an end of 0 means still open
+func ledgerPeriod(from int, to int) (period, error) {
+	start, err := dateFromLedger(from)
+	if err != nil {
+		return period{}, err
+	}
+	if to == 0 {
+		return period{from: start, to: openEnded}, nil
+	}
+	end, err := dateFromLedger(to)
+	if err != nil {
+		return period{}, err
+	}
+	return period{from: start, to: end}, nil
+}
+
+// contains reports whether t falls on a day within the period
+func (p period) contains(t time.Time) bool {
+	return !t.Before(p.from) && !t.After(p.to)
+}
+
+// overlaps reports whether the two periods share at least one day
+func (p period) overlaps(q period) bool {
+	return !p.to.Before(q.from) && !q.to.Before(p.from)
+}
+
+// intersect returns the days common to both periods; ok is false if there are none
+func (p period) intersect(q period) (period, bool) {
+	if !p.overlaps(q) {
+		return period{}, false
+	}
+	result := p
+	if q.from.After(result.from) {
+		result.from = q.from
+	}
+	if q.to.Before(result.to) {
+		result.to = q.to
+	}
+	return result, true
+}
+
+// days returns the number of calendar days in the period, counting both ends
+func (p period) days() int {
+	return int(p.to.Sub(p.from).Hours()/24) + 1
+}
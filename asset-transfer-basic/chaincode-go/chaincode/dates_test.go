@@ -0,0 +1,106 @@
+package chaincode
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		input string
+		date  int
+		err   string
+	}{
+		{"20200131", 20200131, ""},
+		{"2020-01-31", 20200131, ""},
+		{"2020-01-31T23:30:00-05:00", 20200131, ""}, // the day as written, not the UTC day
+		{"2020-01-31T00:30:00+09:00", 20200131, ""},
+		{"20200229", 20200229, ""},
+		{"20190229", 0, `invalid date "20190229": expected YYYYMMDD or ISO 8601`},
+		{"20201301", 0, `invalid date "20201301": expected YYYYMMDD or ISO 8601`},
+		{"2020-1-31", 0, `invalid date "2020-1-31": expected YYYYMMDD or ISO 8601`},
+		{"31/01/2020", 0, `invalid date "31/01/2020": expected YYYYMMDD or ISO 8601`},
+		{"", 0, `invalid date "": expected YYYYMMDD or ISO 8601`},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			date, err := parseLedgerDate(test.input)
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.date, date)
+		})
+	}
+}
+
+func TestPeriodDays(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		to   string
+		days int
+	}{
+		{"single day", "20200131", "20200131", 1},
+		{"across a month end", "20200131", "20200201", 2},
+		{"across a year end", "20191231", "20200101", 2},
+		{"leap February", "20200201", "20200229", 29},
+		{"February", "20210201", "20210228", 28},
+		{"leap year", "20200101", "20201231", 366},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p, err := newPeriod(test.from, test.to)
+			require.NoError(t, err)
+			require.Equal(t, test.days, p.days())
+		})
+	}
+
+	_, err := newPeriod("20200201", "20200131")
+	require.EqualError(t, err, "the end date 20200131 is before the start date 20200201")
+}
+
+func TestPeriodOverlapsAndIntersect(t *testing.T) {
+	january, err := ledgerPeriod(20200101, 20200131)
+	require.NoError(t, err)
+	tests := []struct {
+		name     string
+		from     int
+		to       int
+		overlaps bool
+		from2    int // first day of the intersection
+		to2      int // last day of the intersection
+	}{
+		{"before", 20191201, 20191231, false, 0, 0},
+		{"touching the first day", 20191201, 20200101, true, 20200101, 20200101},
+		{"inside", 20200110, 20200120, true, 20200110, 20200120},
+		{"touching the last day", 20200131, 20200229, true, 20200131, 20200131},
+		{"after", 20200201, 20200229, false, 0, 0},
+		{"open ended from before", 20191201, 0, true, 20200101, 20200131},
+		{"open ended from after", 20200201, 0, false, 0, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			other, err := ledgerPeriod(test.from, test.to)
+			require.NoError(t, err)
+			require.Equal(t, test.overlaps, january.overlaps(other))
+			require.Equal(t, test.overlaps, other.overlaps(january))
+			common, ok := january.intersect(other)
+			require.Equal(t, test.overlaps, ok)
+			if ok {
+				require.Equal(t, test.from2, ledgerDate(common.from))
+				require.Equal(t, test.to2, ledgerDate(common.to))
+			}
+		})
+	}
+
+	open, err := ledgerPeriod(20200101, 0)
+	require.NoError(t, err)
+	require.Equal(t, openEnded, open.to)
+	require.True(t, open.contains(time.Date(2100, time.January, 1, 0, 0, 0, 0, time.UTC)))
+	_, err = ledgerPeriod(20200230, 0)
+	require.EqualError(t, err, "invalid stored date 20200230")
+}
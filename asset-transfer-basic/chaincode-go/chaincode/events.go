@@ -0,0 +1,115 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Chaincode events. Recording a journey, fitting, removing or swapping a component, and issuing or
+// changing the status of a bill each emit an event, named below, with a JSON payload, so downstream
+// systems can follow them without polling. Bill status changes are named "Bill" followed by the new status, eg BillPaid. Fabric
+// only delivers the last event a transaction sets, so transactions making several changes emit one
+// event describing all of them.
+const (
+	EventJourneyCreated   = "JourneyCreated"    // journeyEvent
+	EventJourneysCreated  = "JourneysCreated"   // journeyBatchEvent, for the journeys of one CreateJourneys batch
+	EventComponentAdded   = "ComponentAdded"    // componentEvent
+	EventComponentRemoved = "ComponentRemoved"  // componentEvent
+	EventComponentDeleted = "ComponentDeleted"  // componentEvent
+	EventFuelcellSwapped  = "FuelcellSwapped"   // swapEvent
+	EventBillIssued       = "Bill" + BillIssued // billEvent, for a bill from GenerateBill or CreateNewBill
+	EventBillsIssued      = "BillsIssued"       // billingRunEvent, for the bills of one GenerateBillsForPeriod page
+)
+
+// journeyEvent is the payload of JourneyCreated
+type journeyEvent struct {
+	Journey_ID       string `json:"Journey_ID"`
+	Car_ID           string `json:"Car_ID"`
+	Car_Component_ID string `json:"Car_Component_ID"`
+	Journey_date     int    `json:"Journey_date"`
+	Distance         int    `json:"Distance"`
+	H2_used          int    `json:"H2_used"`
+}
+
+// journeyBatchEvent is the payload of JourneysCreated
+type journeyBatchEvent struct {
+	Journeys []journeyEvent `json:"Journeys"`
+}
+
+// componentEvent is the payload of the component lifecycle events
+type componentEvent struct {
+	Car_Component_ID string `json:"Car_Component_ID"`
+	Car_ID           string `json:"Car_ID"`
+	Fuelcell_ID      string `json:"Fuelcell_ID"`
+	Date_added       int    `json:"Date_added"`
+	Date_removed     int    `json:"Date_removed"`
+}
+
+// swapEvent is the payload of FuelcellSwapped
+type swapEvent struct {
+	Car_ID               string `json:"Car_ID"`
+	Old_Car_Component_ID string `json:"Old_Car_Component_ID"`
+	Old_Fuelcell_ID      string `json:"Old_Fuelcell_ID"`
+	New_Car_Component_ID string `json:"New_Car_Component_ID"`
+	New_Fuelcell_ID      string `json:"New_Fuelcell_ID"`
+	Date                 int    `json:"Date"`
+}
+
+// billingRunEvent is the payload of BillsIssued
+type billingRunEvent struct {
+	Date_from int         `json:"Date_from"`
+	Date_to   int         `json:"Date_to"`
+	Bills     []billEvent `json:"Bills"`
+}
+
+// newJourneyEvent returns the payload describing a new journey
+func newJourneyEvent(journey *JourneyData) journeyEvent {
+	return journeyEvent{
+		Journey_ID:       journey.Journey_ID,
+		Car_ID:           journey.Car_ID,
+		Car_Component_ID: journey.Car_Component_ID,
+		Journey_date:     journey.Journey_date,
+		Distance:         journey.Distance,
+		H2_used:          journey.H2_used,
+	}
+}
+
+// newComponentEvent returns the payload describing a car component
+func newComponentEvent(component *CarComponent) componentEvent {
+	return componentEvent{
+		Car_Component_ID: component.Car_Component_ID,
+		Car_ID:           component.Car_ID,
+		Fuelcell_ID:      component.Fuelcell_ID,
+		Date_added:       component.Date_added,
+		Date_removed:     component.Date_removed,
+	}
+}
+
+// newBillEvent returns the payload describing a bill and the status it has moved from
+func newBillEvent(bill *Bill, previousStatus string, reason string) billEvent {
+	return billEvent{
+		Bill_ID:         bill.Bill_ID,
+		Supplier_ID:     bill.Supplier_ID,
+		Fuelcell_ID:     bill.Fuelcell_ID,
+		Previous_status: previousStatus,
+		Status:          bill.Status,
+		Currency:        bill.Currency,
+		Amount:          bill.Amount,
+		Reason:          reason,
+	}
+}
+
+// emitEvent sets the chaincode event of the transaction
+func emitEvent(ctx contractapi.TransactionContextInterface, name string, payload interface{}) error {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+	err = ctx.GetStub().SetEvent(name, payloadJSON)
+	if err != nil {
+		return fmt.Errorf("failed to set event: %v", err)
+	}
+	return nil
+}
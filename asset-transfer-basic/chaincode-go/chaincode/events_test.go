@@ -0,0 +1,86 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode/mocks"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvents(t *testing.T) {
+	assets := map[string]interface{}{
+		"Car1":       Car{AssetType: "Car", Car_ID: "Car1", Date_of_manufacture: "20200123"},
+		"Supplier1":  Supplier{AssetType: "Supplier", Supplier_ID: "Supplier1", Supplier_name: "Hydrogen1", MSP_ID: "Org2MSP"},
+		"FuelCell1":  FuelcellData{AssetType: "Fuelcell", Fuelcell_ID: "FuelCell1", Supplier_ID: "Supplier1", Currency: "GBP", Date_Received: 20200122},
+		"Component1": CarComponent{AssetType: "Car_Component", Car_Component_ID: "Component1", Car_ID: "Car1", Fuelcell_ID: "FuelCell1", Date_added: 20200123},
+	}
+	fleetOperator := map[string]string{roleAttribute: RoleFleetOperator}
+
+	tests := []struct {
+		name    string
+		submit  func(ctx *mocks.TransactionContext) error
+		event   string
+		payload interface{}
+	}{
+		{
+			name: "journey recorded",
+			submit: func(ctx *mocks.TransactionContext) error {
+				return (&SmartContract{}).CreateJourney(ctx, "Journey1", "Car1", "Component1", "0", "500", "100", 0.3, "Supplier1", "20200123")
+			},
+			event:   EventJourneyCreated,
+			payload: journeyEvent{Journey_ID: "Journey1", Car_ID: "Car1", Car_Component_ID: "Component1", Journey_date: 20200123, Distance: 500, H2_used: 100},
+		},
+		{
+			name: "component removed",
+			submit: func(ctx *mocks.TransactionContext) error {
+				return (&SmartContract{}).RemoveCarComponent(ctx, "Component1", "2020-06-23")
+			},
+			event:   EventComponentRemoved,
+			payload: componentEvent{Car_Component_ID: "Component1", Car_ID: "Car1", Fuelcell_ID: "FuelCell1", Date_added: 20200123, Date_removed: 20200623},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, err := clientWith("Org1MSP", fleetOperator, assets)
+			require.NoError(t, err)
+			chaincodeStub := ctx.GetStub().(*mocks.ChaincodeStub)
+			chaincodeStub.GetStateByPartialCompositeKeyReturns(&mocks.StateQueryIterator{}, nil)
+
+			err = test.submit(ctx)
+			require.NoError(t, err)
+			require.Equal(t, 1, chaincodeStub.SetEventCallCount())
+			name, payloadJSON := chaincodeStub.SetEventArgsForCall(0)
+			require.Equal(t, test.event, name)
+			expectedJSON, err := json.Marshal(test.payload)
+			require.NoError(t, err)
+			require.JSONEq(t, string(expectedJSON), string(payloadJSON))
+		})
+	}
+}
+
+func TestBillingRunPageEvent(t *testing.T) {
+	chaincodeStub := &mocks.ChaincodeStub{}
+	transactionContext := &mocks.TransactionContext{}
+	transactionContext.GetStubReturns(chaincodeStub)
+	billingPeriod, err := newPeriod("20200101", "20200131")
+	require.NoError(t, err)
+
+	_, err = billingRunPage(transactionContext, &BillingRunResult{Bills: []*Bill{}}, billingPeriod)
+	require.NoError(t, err)
+	require.Equal(t, 0, chaincodeStub.SetEventCallCount(), "a page issuing no bills emits no event")
+
+	result := &BillingRunResult{Bills: []*Bill{
+		{Bill_ID: "FuelCell1_20200101_20200131", Supplier_ID: "Supplier1", Fuelcell_ID: "FuelCell1", Currency: "GBP", Amount: "31.50", Status: BillIssued},
+		{Bill_ID: "FuelCell2_20200101_20200131", Supplier_ID: "Supplier2", Fuelcell_ID: "FuelCell2", Currency: "GBP", Amount: "12.00", Status: BillIssued},
+	}}
+	_, err = billingRunPage(transactionContext, result, billingPeriod)
+	require.NoError(t, err)
+	name, payloadJSON := chaincodeStub.SetEventArgsForCall(0)
+	require.Equal(t, EventBillsIssued, name)
+	var event billingRunEvent
+	require.NoError(t, json.Unmarshal(payloadJSON, &event))
+	require.Equal(t, 20200101, event.Date_from)
+	require.Len(t, event.Bills, 2)
+	require.Equal(t, Money("12.00"), event.Bills[1].Amount)
+}
@@ -0,0 +1,138 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Audit trail. The history of an asset comes from GetHistoryForKey, which needs the peer's history
+// database enabled (core.ledger.history.enableHistoryDatabase, on by default). It shows every version
+// an asset has been through but does not replace Date_added and Date_removed on a CarComponent, which
+// billing queries by. The history database does not record who submitted a transaction, so putAsset
+// stores the MSP of the submitter in the Last_submitted_by field of every asset it writes.
+
+// AssetVersion is one version of an asset in its history
+type AssetVersion struct {
+	Tx_ID     string `json:"Tx_ID"`
+	Timestamp string `json:"Timestamp"` // RFC 3339 time the transaction was created
+	Is_delete bool   `json:"Is_delete"`
+	MSP_ID    string `json:"MSP_ID"` // organisation of the submitter, empty for deletions and versions written before submitters were recorded
+	Value     string `json:"Value"`  // the asset's JSON as written, empty when deleted
+}
+
+// JourneyHistory is the history of one journey charged on a bill
+type JourneyHistory struct {
+	Journey_ID string          `json:"Journey_ID"`
+	History    []*AssetVersion `json:"History"`
+}
+
+// BillAudit is the history of a bill together with the history of every journey it charged
+type BillAudit struct {
+	Bill_ID  string            `json:"Bill_ID"`
+	History  []*AssetVersion   `json:"History"`
+	Journeys []*JourneyHistory `json:"Journeys"`
+}
+
+// GetAssetHistory returns every version of an asset of the given AssetType, most recent first. Bills and
+// credit notes may only be audited by those who may read them.
+func (s *SmartContract) GetAssetHistory(ctx contractapi.TransactionContextInterface, assetType string, id string) ([]*AssetVersion, error) {
+	if !assetTypes[assetType] {
+		return nil, fmt.Errorf("unknown asset type %s", assetType)
+	}
+	var err error
+	switch assetType {
+	case "Bill":
+		_, err = s.ReadBill(ctx, id)
+	case "Credit_Note":
+		_, err = s.ReadCreditNote(ctx, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return assetHistory(ctx, assetType, id)
+}
+
+// GetBillAudit returns the history of a bill alongside the history of each journey it marked as billed
+func (s *SmartContract) GetBillAudit(ctx contractapi.TransactionContextInterface, billID string) (*BillAudit, error) {
+	bill, err := s.ReadBill(ctx, billID)
+	if err != nil {
+		return nil, err
+	}
+	history, err := assetHistory(ctx, "Bill", billID)
+	if err != nil {
+		return nil, err
+	}
+	audit := BillAudit{Bill_ID: billID, History: history, Journeys: []*JourneyHistory{}}
+	// bills issued before journeys were recorded do not say which journeys they charged
+	for _, journeyID := range bill.Journey_IDs {
+		journeyHistory, err := assetHistory(ctx, "Journey", journeyID)
+		if err != nil {
+			return nil, err
+		}
+		audit.Journeys = append(audit.Journeys, &JourneyHistory{Journey_ID: journeyID, History: journeyHistory})
+	}
+	return &audit, nil
+}
+
+// assetHistory returns the versions of an asset, most recent first. For an asset moved from its bare ID
+// by MigrateAssetKeys the versions written under the bare ID follow those under its namespaced key.
+func assetHistory(ctx contractapi.TransactionContextInterface, assetType string, id string) ([]*AssetVersion, error) {
+	key, err := assetKey(ctx, assetType, id)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := keyHistory(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		return versions, nil
+	}
+	legacyVersions, err := keyHistory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// the migration deleted the bare ID in the transaction that first wrote the namespaced key
+	first := versions[len(versions)-1]
+	if len(legacyVersions) > 0 && legacyVersions[0].Is_delete && legacyVersions[0].Tx_ID == first.Tx_ID {
+		versions = append(versions, legacyVersions[1:]...)
+	}
+	return versions, nil
+}
+
+// keyHistory returns the versions of a world state key, most recent first
+func keyHistory(ctx contractapi.TransactionContextInterface, key string) ([]*AssetVersion, error) {
+	resultsIterator, err := ctx.GetStub().GetHistoryForKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	versions := []*AssetVersion{}
+	for resultsIterator.HasNext() {
+		modification, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var submitter struct {
+			Last_submitted_by string `json:"Last_submitted_by"`
+		}
+		if !modification.IsDelete {
+			err = json.Unmarshal(modification.Value, &submitter)
+			if err != nil {
+				return nil, fmt.Errorf("failed to unmarshal version %s of %s: %v", modification.TxId, key, err)
+			}
+		}
+		versions = append(versions, &AssetVersion{
+			Tx_ID:     modification.TxId,
+			Timestamp: modification.Timestamp.AsTime().Format(time.RFC3339),
+			Is_delete: modification.IsDelete,
+			MSP_ID:    submitter.Last_submitted_by,
+			Value:     string(modification.Value),
+		})
+	}
+	return versions, nil
+}
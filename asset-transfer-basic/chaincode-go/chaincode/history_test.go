@@ -0,0 +1,118 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/simulator"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetAssetHistory(t *testing.T) {
+	ledger := simulator.NewLedger(time.Date(2020, time.March, 2, 9, 0, 0, 0, time.UTC))
+	contract := &chaincode.SmartContract{}
+
+	// a car written before keys were namespaced, then migrated, updated and deleted
+	legacy := ledger.NewStub()
+	require.NoError(t, legacy.PutState("Car9", []byte(`{"AssetType":"Car","Car_ID":"Car9","Date_of_manufacture":"20200123","Misc":""}`)))
+	require.NoError(t, legacy.Commit())
+	require.NoError(t, ledger.Submit(adminClient, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := contract.MigrateAssetKeys(ctx, 25, "")
+		return err
+	}))
+	require.NoError(t, ledger.Submit(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.UpdateCar(ctx, "Car9", "20200124", "corrected date")
+	}))
+	require.NoError(t, ledger.Submit(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.DeleteCar(ctx, "Car9")
+	}))
+
+	var history []*chaincode.AssetVersion
+	err := ledger.Evaluate(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		history, err = contract.GetAssetHistory(ctx, "Car", "Car9")
+		return err
+	})
+	require.NoError(t, err)
+	require.Len(t, history, 4)
+
+	tests := []struct {
+		txID     string
+		isDelete bool
+		mspID    string
+		date     string
+	}{
+		{"tx4", true, "", ""}, // a deletion leaves no value to record its submitter in
+		{"tx3", false, "Org1MSP", "20200124"},
+		{"tx2", false, "", "20200123"}, // moved unchanged by the migration
+		{"tx1", false, "", "20200123"}, // written under the bare ID before submitters were recorded
+	}
+	for i, test := range tests {
+		version := history[i]
+		require.Equal(t, test.txID, version.Tx_ID)
+		require.Equal(t, test.isDelete, version.Is_delete)
+		require.Equal(t, test.mspID, version.MSP_ID)
+		if test.isDelete {
+			require.Empty(t, version.Value)
+			continue
+		}
+		var car chaincode.Car
+		require.NoError(t, json.Unmarshal([]byte(version.Value), &car))
+		require.Equal(t, test.date, car.Date_of_manufacture)
+	}
+	require.Equal(t, "2020-03-02T09:00:03Z", history[0].Timestamp)
+
+	err = ledger.Evaluate(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := contract.GetAssetHistory(ctx, "Widget", "Car9")
+		return err
+	})
+	require.EqualError(t, err, "unknown asset type Widget")
+}
+
+func TestGetBillAudit(t *testing.T) {
+	ledger := simulator.NewLedger(time.Date(2020, time.March, 2, 9, 0, 0, 0, time.UTC))
+	contract := &chaincode.SmartContract{}
+	require.NoError(t, ledger.Submit(adminClient, contract.InitLedger))
+	require.NoError(t, ledger.Submit(adminClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.GenerateBill(ctx, "Bill1", "FuelCell1", "20200101", "20200229")
+	}))
+
+	supplier1 := &simulator.Client{MSPID: "Org2MSP", Attributes: map[string]string{"billing.role": chaincode.RoleSupplier, "billing.supplier_id": "Supplier1"}}
+	var audit *chaincode.BillAudit
+	err := ledger.Evaluate(supplier1, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		audit, err = contract.GetBillAudit(ctx, "Bill1")
+		return err
+	})
+	require.NoError(t, err)
+	require.Len(t, audit.History, 1)
+	require.Equal(t, "Org1MSP", audit.History[0].MSP_ID)
+	require.Len(t, audit.Journeys, 2)
+	for i, journeyID := range []string{"Journey1", "Journey2"} {
+		journey := audit.Journeys[i]
+		require.Equal(t, journeyID, journey.Journey_ID)
+		require.Len(t, journey.History, 2, "recorded by InitLedger then marked billed")
+		require.Equal(t, audit.History[0].Tx_ID, journey.History[0].Tx_ID)
+		require.Equal(t, "Org1MSP", journey.History[0].MSP_ID)
+		var billed chaincode.JourneyData
+		require.NoError(t, json.Unmarshal([]byte(journey.History[0].Value), &billed))
+		require.True(t, billed.Billed)
+	}
+
+	supplier2 := &simulator.Client{MSPID: "Org2MSP", Attributes: map[string]string{"billing.role": chaincode.RoleSupplier, "billing.supplier_id": "Supplier2"}}
+	for _, audit := range []func(ctx contractapi.TransactionContextInterface) error{
+		func(ctx contractapi.TransactionContextInterface) error {
+			_, err := contract.GetBillAudit(ctx, "Bill1")
+			return err
+		},
+		func(ctx contractapi.TransactionContextInterface) error {
+			_, err := contract.GetAssetHistory(ctx, "Bill", "Bill1")
+			return err
+		},
+	} {
+		require.Error(t, ledger.Evaluate(supplier2, audit), "another supplier's bill must not be audited")
+	}
+}
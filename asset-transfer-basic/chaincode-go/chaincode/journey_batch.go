@@ -0,0 +1,167 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Batch journey uploads. Cars upload their telemetry from the depot a batch at a time. A batch is checked
+// as a whole, against the ledger and against the other journeys in it, and is recorded in full or not at
+// all: if any journey is rejected the transaction fails listing every rejected journey, so the upload can
+// be corrected and resent. Batches are capped so one transaction's read and write sets stay a size peers
+// will endorse.
+
+// maxJourneyBatch is the most journeys CreateJourneys records in one transaction
+const maxJourneyBatch = 100
+
+// JourneyRowError is why one journey in a batch was rejected
+type JourneyRowError struct {
+	Row        int    `json:"Row"` // index of the journey in the batch, from 0
+	Journey_ID string `json:"Journey_ID"`
+	Message    string `json:"Message"`
+}
+
+// JourneyBatchError lists every journey rejected from a batch. Its message ends with the rows as a JSON
+// array so the uploader can match each back to the journey it sent.
+type JourneyBatchError struct {
+	Journeys int               `json:"Journeys"` // journeys in the batch
+	Rows     []JourneyRowError `json:"Rows"`
+}
+
+func (e *JourneyBatchError) Error() string {
+	rowsJSON, err := json.Marshal(e.Rows)
+	if err != nil {
+		return fmt.Sprintf("rejected %d of %d journeys, none were recorded", len(e.Rows), e.Journeys)
+	}
+	return fmt.Sprintf("rejected %d of %d journeys, none were recorded: %s", len(e.Rows), e.Journeys, rowsJSON)
+}
+
+// reject records why a row of the batch was rejected
+func (e *JourneyBatchError) reject(row int, journeyID string, err error) {
+	e.Rows = append(e.Rows, JourneyRowError{Row: row, Journey_ID: journeyID, Message: err.Error()})
+}
+
+// rejected reports whether a row of the batch has already been rejected
+func (e *JourneyBatchError) rejected(row int) bool {
+	for _, rowErr := range e.Rows {
+		if rowErr.Row == row {
+			return true
+		}
+	}
+	return false
+}
+
+// CreateJourneys records a batch of journeys, returning how many were recorded. Each journey is checked
+// as CreateJourney checks one, except that the supplier is taken to be that of the fuel cell fitted;
+// AssetType may be left empty and Billed must be false. A batch may hold at most 100 journeys.
+func (s *SmartContract) CreateJourneys(ctx contractapi.TransactionContextInterface, journeys []JourneyData) (int, error) {
+	err := assertFleetOperator(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if len(journeys) == 0 {
+		return 0, fmt.Errorf("the batch holds no journeys")
+	}
+	if len(journeys) > maxJourneyBatch {
+		return 0, fmt.Errorf("the batch holds %d journeys, at most %d may be recorded at once", len(journeys), maxJourneyBatch)
+	}
+
+	batchErr := &JourneyBatchError{Journeys: len(journeys)}
+	rows := make(map[string]int)                   // row of each Journey_ID
+	carJourneys := make(map[string][]*JourneyData) // each car's recorded journeys, read once per car
+	for i := range journeys {
+		journey := &journeys[i]
+		if journey.AssetType == "" {
+			journey.AssetType = "Journey"
+		}
+		if row, ok := rows[journey.Journey_ID]; ok {
+			batchErr.reject(i, journey.Journey_ID, fmt.Errorf("the Journey %s is already in the batch at row %d", journey.Journey_ID, row))
+			continue
+		}
+		rows[journey.Journey_ID] = i
+		err = s.checkBatchJourney(ctx, journey, carJourneys)
+		if err != nil {
+			batchErr.reject(i, journey.Journey_ID, err)
+		}
+	}
+	// the odometer is checked against the car's other journeys in the batch as well as those recorded
+	for i := range journeys {
+		journey := &journeys[i]
+		if batchErr.rejected(i) || carJourneys[journey.Car_ID] == nil {
+			continue
+		}
+		err = checkOdometer(journey, append(earlierInBatch(journeys, rows, i), carJourneys[journey.Car_ID]...))
+		if err != nil {
+			batchErr.reject(i, journey.Journey_ID, err)
+		}
+	}
+	if len(batchErr.Rows) > 0 {
+		return 0, batchErr
+	}
+
+	event := journeyBatchEvent{Journeys: []journeyEvent{}}
+	for i := range journeys {
+		err = putJourney(ctx, &journeys[i])
+		if err != nil {
+			return 0, err
+		}
+		event.Journeys = append(event.Journeys, newJourneyEvent(&journeys[i]))
+	}
+	return len(journeys), emitEvent(ctx, EventJourneysCreated, event)
+}
+
+// checkBatchJourney checks one journey of a batch against the ledger, reading the journeys already
+// recorded for its car into carJourneys if they have not been read yet
+func (s *SmartContract) checkBatchJourney(ctx contractapi.TransactionContextInterface, journey *JourneyData, carJourneys map[string][]*JourneyData) error {
+	err := journey.validate()
+	if err != nil {
+		return err
+	}
+	if journey.Billed {
+		return fmt.Errorf("a new journey must not already be billed")
+	}
+	exists, err := assetExists(ctx, "Journey", journey.Journey_ID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("the asset %s already exists", journey.Journey_ID)
+	}
+	component, err := s.fittedComponent(ctx, journey.Car_ID, journey.Car_Component_ID, journey.Journey_date)
+	if err != nil {
+		return err
+	}
+	_, err = s.ReadFuelcell(ctx, component.Fuelcell_ID)
+	if err != nil {
+		return err
+	}
+	if carJourneys[journey.Car_ID] == nil {
+		previousJourneys, err := s.GetAllJourneysofCar(ctx, journey.Car_ID)
+		if err != nil {
+			return err
+		}
+		carJourneys[journey.Car_ID] = append([]*JourneyData{}, previousJourneys...) // not nil once read
+	}
+	return nil
+}
+
+// earlierInBatch returns the other journeys of the batch made by the same car as row i which come
+// before it: those on an earlier date, or on the same date starting lower on the odometer. Duplicate
+// rows, whose row in rows is another, are left out.
+func earlierInBatch(journeys []JourneyData, rows map[string]int, i int) []*JourneyData {
+	journey := &journeys[i]
+	var earlier []*JourneyData
+	for j := range journeys {
+		other := &journeys[j]
+		if j == i || rows[other.Journey_ID] != j || other.Car_ID != journey.Car_ID {
+			continue
+		}
+		if other.Journey_date < journey.Journey_date ||
+			(other.Journey_date == journey.Journey_date && (other.Odo_start < journey.Odo_start || (other.Odo_start == journey.Odo_start && j < i))) {
+			earlier = append(earlier, other)
+		}
+	}
+	return earlier
+}
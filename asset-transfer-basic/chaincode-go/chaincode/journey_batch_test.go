@@ -0,0 +1,90 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/simulator"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateJourneys(t *testing.T) {
+	ledger := simulator.NewLedger(time.Date(2022, time.March, 2, 9, 0, 0, 0, time.UTC))
+	contract := &chaincode.SmartContract{}
+	require.NoError(t, ledger.Submit(adminClient, contract.InitLedger))
+	createJourneys := func(journeys []chaincode.JourneyData) (int, error) {
+		var recorded int
+		err := ledger.Submit(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			recorded, err = contract.CreateJourneys(ctx, journeys)
+			return err
+		})
+		return recorded, err
+	}
+
+	// Car1 has already covered 0 to 1500 by 20200127
+	recorded, err := createJourneys([]chaincode.JourneyData{
+		{Journey_ID: "Journey8", Car_ID: "Car1", Car_Component_ID: "Component1", Odo_start: 1600, Distance: 50, H2_used: 5, Efficiency: 0.5, Journey_date: 20200201},
+		{Journey_ID: "Journey7", Car_ID: "Car1", Car_Component_ID: "Component1", Odo_start: 1500, Distance: 100, H2_used: 10, Efficiency: 0.5, Journey_date: 20200201},
+		{Journey_ID: "Journey9", Car_ID: "Car2", Car_Component_ID: "Component2", Odo_start: 500, Distance: 20, H2_used: 2, Efficiency: 0.4, Journey_date: 20210201},
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, recorded)
+	for _, id := range []string{"Journey7", "Journey8", "Journey9"} {
+		require.NotNil(t, ledger.State(compositeKey(t, "Journey", id)), id)
+	}
+	events := ledger.Events()
+	event := events[len(events)-1]
+	require.Equal(t, chaincode.EventJourneysCreated, event.EventName)
+	var payload struct{ Journeys []struct{ Journey_ID string } }
+	require.NoError(t, json.Unmarshal(event.Payload, &payload))
+	require.Len(t, payload.Journeys, 3)
+
+	recorded, err = createJourneys([]chaincode.JourneyData{
+		{Journey_ID: "Journey10", Car_ID: "Car1", Car_Component_ID: "Component1", Odo_start: 1700, Distance: 100, H2_used: 10, Efficiency: 0.5, Journey_date: 20200202},
+		{Journey_ID: "Journey11", Car_ID: "Car1", Car_Component_ID: "Component1", Odo_start: 1750, Distance: 100, H2_used: 10, Efficiency: 0.5, Journey_date: 20200202},
+		{Journey_ID: "Journey10", Car_ID: "Car1", Car_Component_ID: "Component1", Odo_start: 2000, Distance: 100, H2_used: 10, Efficiency: 0.5, Journey_date: 20200203},
+		{Journey_ID: "Journey1", Car_ID: "Car1", Car_Component_ID: "Component1", Odo_start: 2100, Distance: 100, H2_used: 10, Efficiency: 0.5, Journey_date: 20200204},
+		{Journey_ID: "Journey12", Car_ID: "Car2", Car_Component_ID: "Component2", Odo_start: 600, Distance: 10, H2_used: 1, Efficiency: 0.5, Journey_date: 20210701},
+		{Journey_ID: "Journey13", Car_ID: "Car3", Car_Component_ID: "Component3", Odo_start: 600, Distance: 10, H2_used: 1, Efficiency: 1.5, Journey_date: 20210701},
+		{Journey_ID: "Journey14", Car_ID: "Car3", Car_Component_ID: "Component3", Odo_start: 600, Distance: 10, H2_used: 1, Efficiency: 0.5, Journey_date: 20210701, Billed: true},
+		{Journey_ID: "Journey15", Car_ID: "Car1", Car_Component_ID: "Component1", Odo_start: 2200, Distance: 100, H2_used: 10, Efficiency: 0.5, Journey_date: 20200205},
+	})
+	require.Zero(t, recorded)
+	var batchErr *chaincode.JourneyBatchError
+	require.True(t, errors.As(err, &batchErr), err)
+	require.Equal(t, 8, batchErr.Journeys)
+	rejected := make(map[int]string)
+	for _, row := range batchErr.Rows {
+		rejected[row.Row] = row.Message
+	}
+	require.Len(t, rejected, len(batchErr.Rows), "each row is rejected once")
+	for row, message := range map[int]string{
+		1: "the Odo_start 1750 is below the end of Journey Journey10 at 1800",
+		2: "the Journey Journey10 is already in the batch at row 0",
+		3: "the asset Journey1 already exists",
+		4: "the Car_Component Component2 was not fitted to Car Car2 on 20210701",
+		6: "a new journey must not already be billed",
+	} {
+		require.Equal(t, message, rejected[row], fmt.Sprintf("row %d", row))
+	}
+	require.Contains(t, rejected[5], "Efficiency")
+	require.NotContains(t, rejected, 0)
+	require.NotContains(t, rejected, 7)
+	require.Nil(t, ledger.State(compositeKey(t, "Journey", "Journey10")), "nothing is recorded from a rejected batch")
+
+	_, err = createJourneys(nil)
+	require.EqualError(t, err, "the batch holds no journeys")
+	_, err = createJourneys(make([]chaincode.JourneyData, 101))
+	require.EqualError(t, err, "the batch holds 101 journeys, at most 100 may be recorded at once")
+	err = ledger.Submit(adminClient, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := contract.CreateJourneys(ctx, []chaincode.JourneyData{{Journey_ID: "Journey16"}})
+		return err
+	})
+	require.Error(t, err, "only fleet operators record journeys")
+}
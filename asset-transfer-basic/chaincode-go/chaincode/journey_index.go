@@ -0,0 +1,127 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Composite key indexes over journeys. Each journey is written with an entry in each index whose key
+// holds the car or component, the journey date and the Journey_ID, so journeys can be found by car or
+// component, already in date order, with GetStateByPartialCompositeKey. This works on LevelDB peers as
+// well as CouchDB and reads only the journeys asked for rather than the whole namespace.
+const (
+	journeyByCarIndex       = "journey~car~date"
+	journeyByComponentIndex = "journey~component~date"
+)
+
+// RebuildJourneyIndexes writes the index entries for every journey in the world state. MigrateAssetKeys
+// indexes the journeys it moves, so this only needs to be run once on ledgers holding journeys written
+// under namespaced keys before the indexes existed, and is safe to repeat.
+func (s *SmartContract) RebuildJourneyIndexes(ctx contractapi.TransactionContextInterface) (int, error) {
+	err := assertBillingAdmin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	// every journey is keyed under the Journey object type, see keys.go
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey("Journey", []string{})
+	if err != nil {
+		return 0, err
+	}
+	defer resultsIterator.Close()
+
+	indexed := 0
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return 0, err
+		}
+		var asset JourneyData
+		err = json.Unmarshal(queryResponse.Value, &asset)
+		if err != nil {
+			return 0, err
+		}
+		err = putJourneyIndexes(ctx, &asset)
+		if err != nil {
+			return 0, err
+		}
+		indexed++
+	}
+	return indexed, nil
+}
+
+// putJourney writes a new journey together with its index entries
+func putJourney(ctx contractapi.TransactionContextInterface, journey *JourneyData) error {
+	err := putAsset(ctx, journey)
+	if err != nil {
+		return err
+	}
+	return putJourneyIndexes(ctx, journey)
+}
+
+// putJourneyIndexes writes the index entries of a journey; the value is a single null byte as the
+// key itself carries everything the index is used for
+func putJourneyIndexes(ctx contractapi.TransactionContextInterface, journey *JourneyData) error {
+	date := strconv.Itoa(journey.Journey_date)
+	for index, attribute := range map[string]string{
+		journeyByCarIndex:       journey.Car_ID,
+		journeyByComponentIndex: journey.Car_Component_ID,
+	} {
+		indexKey, err := ctx.GetStub().CreateCompositeKey(index, []string{attribute, date, journey.Journey_ID})
+		if err != nil {
+			return fmt.Errorf("failed to create the %s index key for Journey %s: %v", index, journey.Journey_ID, err)
+		}
+		err = ctx.GetStub().PutState(indexKey, []byte{0x00})
+		if err != nil {
+			return fmt.Errorf("failed to put to world state. %v", err)
+		}
+	}
+	return nil
+}
+
+// journeysByIndex returns the journeys whose index entries start with the given attributes, in date
+// order. If within is not nil, journeys dated outside it are skipped without being read.
+func journeysByIndex(ctx contractapi.TransactionContextInterface, index string, attributes []string, within *period) ([]*JourneyData, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(index, attributes)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	var assets []*JourneyData
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, err
+		}
+		if len(keyParts) != 3 {
+			return nil, fmt.Errorf("malformed %s index key %q", index, queryResponse.Key)
+		}
+		if within != nil {
+			date, err := strconv.Atoi(keyParts[1])
+			if err != nil {
+				return nil, err
+			}
+			journeyDate, err := dateFromLedger(date)
+			if err != nil {
+				return nil, err
+			}
+			if !within.contains(journeyDate) {
+				continue
+			}
+		}
+		var asset JourneyData
+		err = readAsset(ctx, keyParts[2], "Journey", &asset)
+		if err != nil {
+			return nil, err
+		}
+		assets = append(assets, &asset)
+	}
+	return assets, nil
+}
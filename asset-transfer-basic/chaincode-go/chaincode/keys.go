@@ -0,0 +1,218 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// World state keys. Every asset is stored under a composite key of its AssetType and ID, so a Supplier
+// and a Car may share an ID without one overwriting the other, and a lookup of one type never finds an
+// asset of another. Ledgers written before keys were namespaced hold assets under their bare IDs and
+// must be re-keyed once with MigrateAssetKeys straight after the chaincode is upgraded. Rates in the
+// tariff collection keep the bare Tariff_ID; the line items of bills held alongside them are under the
+// composite key of Bill_Lines and the Bill_ID.
+
+// assetTypes are the AssetTypes kept in the world state
+var assetTypes = map[string]bool{
+	"Car":           true,
+	"Car_Component": true,
+	"Supplier":      true,
+	"Fuelcell":      true,
+	"Journey":       true,
+	"Bill":          true,
+	"Credit_Note":   true,
+	"Tariff":        true,
+}
+
+// storedAsset is an asset kept in the world state under the key of its AssetType and ID
+type storedAsset interface {
+	validatedAsset
+	key() (assetType string, id string)
+	submittedBy(mspID string) storedAsset // a copy of the asset recording the MSP writing it
+}
+
+func (asset Car) key() (string, string)          { return "Car", asset.Car_ID }
+func (asset CarComponent) key() (string, string) { return "Car_Component", asset.Car_Component_ID }
+func (asset Supplier) key() (string, string)     { return "Supplier", asset.Supplier_ID }
+func (asset FuelcellData) key() (string, string) { return "Fuelcell", asset.Fuelcell_ID }
+func (asset JourneyData) key() (string, string)  { return "Journey", asset.Journey_ID }
+func (asset Bill) key() (string, string)         { return "Bill", asset.Bill_ID }
+func (asset CreditNote) key() (string, string)   { return "Credit_Note", asset.Credit_note_ID }
+func (asset Tariff) key() (string, string)       { return "Tariff", asset.Tariff_ID }
+
+func (asset Car) submittedBy(mspID string) storedAsset {
+	asset.Last_submitted_by = mspID
+	return asset
+}
+
+func (asset CarComponent) submittedBy(mspID string) storedAsset {
+	asset.Last_submitted_by = mspID
+	return asset
+}
+
+func (asset Supplier) submittedBy(mspID string) storedAsset {
+	asset.Last_submitted_by = mspID
+	return asset
+}
+
+func (asset FuelcellData) submittedBy(mspID string) storedAsset {
+	asset.Last_submitted_by = mspID
+	return asset
+}
+
+func (asset JourneyData) submittedBy(mspID string) storedAsset {
+	asset.Last_submitted_by = mspID
+	return asset
+}
+
+func (asset Bill) submittedBy(mspID string) storedAsset {
+	asset.Last_submitted_by = mspID
+	return asset
+}
+
+func (asset CreditNote) submittedBy(mspID string) storedAsset {
+	asset.Last_submitted_by = mspID
+	return asset
+}
+
+func (asset Tariff) submittedBy(mspID string) storedAsset {
+	asset.Last_submitted_by = mspID
+	return asset
+}
+
+// AssetExists returns true when an asset of the given AssetType, eg Car or Fuelcell, exists with the ID
+func (s *SmartContract) AssetExists(ctx contractapi.TransactionContextInterface, assetType string, id string) (bool, error) {
+	if !assetTypes[assetType] {
+		return false, fmt.Errorf("unknown asset type %s", assetType)
+	}
+	return assetExists(ctx, assetType, id)
+}
+
+// maxMigrationPage caps the keys examined by one MigrateAssetKeys call so that a single transaction stays
+// well inside the peer's endorsement and message size limits
+const maxMigrationPage = 100
+
+// MigrationResult reports one page of a MigrateAssetKeys run
+type MigrationResult struct {
+	Migrated int    `json:"Migrated"` // assets moved to their namespaced keys by this call
+	Bookmark string `json:"Bookmark"` // pass to the next call, empty once every key has been examined
+}
+
+// MigrateAssetKeys moves every asset still held under its bare ID to its namespaced key, examining a page
+// of bare keys at a time in key order. Call it again with the returned bookmark until the bookmark comes
+// back empty. Values are copied unchanged, and each journey moved is written to the journey indexes so it
+// can be billed. It only needs to be run once, after upgrading from a chaincode without namespaced keys,
+// and is safe to repeat.
+func (s *SmartContract) MigrateAssetKeys(ctx contractapi.TransactionContextInterface, pageSize int, bookmark string) (*MigrationResult, error) {
+	err := assertBillingAdmin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if pageSize < 1 || pageSize > maxMigrationPage {
+		return nil, fmt.Errorf("the page size must be between 1 and %d", maxMigrationPage)
+	}
+	// a range query with an empty endKey runs to the end of the chaincode namespace, skipping composite
+	// keys, and the bookmark is the last key examined by the previous page
+	resultsIterator, err := ctx.GetStub().GetStateByRange(bookmark, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	result := MigrationResult{}
+	examined := 0
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		if queryResponse.Key <= bookmark {
+			continue // covered by an earlier page
+		}
+		if examined == pageSize {
+			return &result, nil
+		}
+		examined++
+		result.Bookmark = queryResponse.Key
+
+		var header struct {
+			AssetType string `json:"AssetType"`
+		}
+		err = json.Unmarshal(queryResponse.Value, &header)
+		if err != nil {
+			continue // not an asset written by this contract
+		}
+		if !assetTypes[header.AssetType] {
+			continue
+		}
+		exists, err := assetExists(ctx, header.AssetType, queryResponse.Key)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, fmt.Errorf("the %s %s is held under both its bare and namespaced keys", header.AssetType, queryResponse.Key)
+		}
+		key, err := assetKey(ctx, header.AssetType, queryResponse.Key)
+		if err != nil {
+			return nil, err
+		}
+		err = ctx.GetStub().PutState(key, queryResponse.Value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to put to world state. %v", err)
+		}
+		err = ctx.GetStub().DelState(queryResponse.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to delete from world state: %v", err)
+		}
+		if header.AssetType == "Journey" {
+			var journey JourneyData
+			err = json.Unmarshal(queryResponse.Value, &journey)
+			if err != nil {
+				return nil, err
+			}
+			err = putJourneyIndexes(ctx, &journey)
+			if err != nil {
+				return nil, err
+			}
+		}
+		result.Migrated++
+	}
+	result.Bookmark = "" // every key has been examined
+	return &result, nil
+}
+
+// assetKey returns the world state key of an asset
+func assetKey(ctx contractapi.TransactionContextInterface, assetType string, id string) (string, error) {
+	if id == "" {
+		return "", fmt.Errorf("the %s ID must not be empty", assetType)
+	}
+	return ctx.GetStub().CreateCompositeKey(assetType, []string{id})
+}
+
+// assetExists reports whether an asset of the given type exists with the ID
+func assetExists(ctx contractapi.TransactionContextInterface, assetType string, id string) (bool, error) {
+	key, err := assetKey(ctx, assetType, id)
+	if err != nil {
+		return false, err
+	}
+	assetJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return false, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	return assetJSON != nil, nil
+}
+
+// deleteAsset removes an asset from the world state
+func deleteAsset(ctx contractapi.TransactionContextInterface, assetType string, id string) error {
+	key, err := assetKey(ctx, assetType, id)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().DelState(key)
+	if err != nil {
+		return fmt.Errorf("failed to delete from world state: %v", err)
+	}
+	return nil
+}
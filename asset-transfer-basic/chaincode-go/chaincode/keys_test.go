@@ -0,0 +1,140 @@
+package chaincode_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/simulator"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAssetsOfDifferentTypesShareIDs(t *testing.T) {
+	ledger := simulator.NewLedger(time.Date(2020, time.March, 2, 9, 0, 0, 0, time.UTC))
+	contract := &chaincode.SmartContract{}
+	require.NoError(t, ledger.Submit(adminClient, contract.InitLedger))
+
+	err := ledger.Submit(adminClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.CreateSupplier(ctx, "Car1", "Car One Hydrogen", "Org2MSP", "")
+	})
+	require.NoError(t, err)
+
+	err = ledger.Evaluate(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+		car, err := contract.ReadCar(ctx, "Car1")
+		require.NoError(t, err)
+		require.Equal(t, "20200123", car.Date_of_manufacture)
+		supplier, err := contract.ReadSupplier(ctx, "Car1")
+		require.NoError(t, err)
+		require.Equal(t, "Car One Hydrogen", supplier.Supplier_name)
+
+		for assetType, expected := range map[string]bool{"Car": true, "Supplier": true, "Fuelcell": false, "Bill": false} {
+			exists, err := contract.AssetExists(ctx, assetType, "Car1")
+			require.NoError(t, err)
+			require.Equal(t, expected, exists, assetType)
+		}
+		_, err = contract.AssetExists(ctx, "Widget", "Car1")
+		return err
+	})
+	require.EqualError(t, err, "unknown asset type Widget")
+}
+
+func TestMigrateAssetKeys(t *testing.T) {
+	ledger := simulator.NewLedger(time.Date(2020, time.March, 2, 9, 0, 0, 0, time.UTC))
+	contract := &chaincode.SmartContract{}
+	migrate := func(client *simulator.Client, pageSize int, bookmark string) (*chaincode.MigrationResult, error) {
+		var result *chaincode.MigrationResult
+		err := ledger.Submit(client, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			result, err = contract.MigrateAssetKeys(ctx, pageSize, bookmark)
+			return err
+		})
+		return result, err
+	}
+
+	// a ledger written before keys were namespaced
+	legacy := ledger.NewStub()
+	require.NoError(t, legacy.PutState("Car1", []byte(`{"AssetType":"Car","Car_ID":"Car1","Date_of_manufacture":"20200123","Misc":""}`)))
+	require.NoError(t, legacy.PutState("Journey1", []byte(`{"AssetType":"Journey","Journey_ID":"Journey1","Car_ID":"Car1","Car_Component_ID":"Component1",`+
+		`"Odo_start":0,"Distance":10,"H2_used":1,"Efficiency":0.5,"Journey_date":20200201,"Billed":false,"Misc":""}`)))
+	require.NoError(t, legacy.PutState("Supplier1", []byte(`{"AssetType":"Supplier","Supplier_ID":"Supplier1","Supplier_name":"Hydrogen1","MSP_ID":"Org2MSP","Misc":""}`)))
+	require.NoError(t, legacy.PutState("settings", []byte(`not an asset`)))
+	require.NoError(t, legacy.Commit())
+
+	_, err := migrate(fleetClient, 2, "")
+	require.Error(t, err, "only the billing admin may migrate the ledger")
+	_, err = migrate(adminClient, 0, "")
+	require.EqualError(t, err, "the page size must be between 1 and 100")
+	_, err = migrate(adminClient, 101, "")
+	require.EqualError(t, err, "the page size must be between 1 and 100")
+
+	result, err := migrate(adminClient, 2, "")
+	require.NoError(t, err)
+	require.Equal(t, chaincode.MigrationResult{Migrated: 2, Bookmark: "Journey1"}, *result)
+	require.Nil(t, ledger.State("Car1"))
+	require.NotNil(t, ledger.State("Supplier1"))
+	result, err = migrate(adminClient, 2, result.Bookmark)
+	require.NoError(t, err)
+	require.Equal(t, chaincode.MigrationResult{Migrated: 1}, *result, "keys which are not assets are examined but left in place")
+	result, err = migrate(adminClient, 100, "")
+	require.NoError(t, err)
+	require.Equal(t, chaincode.MigrationResult{}, *result)
+
+	require.Nil(t, ledger.State("Supplier1"))
+	require.NotNil(t, ledger.State("settings"))
+	err = ledger.Evaluate(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := contract.ReadCar(ctx, "Car1")
+		if err != nil {
+			return err
+		}
+		// the moved journey is indexed, so it is found by its car
+		journeys, err := contract.GetAllJourneysofCar(ctx, "Car1")
+		require.Len(t, journeys, 1)
+		require.Equal(t, "Journey1", journeys[0].Journey_ID)
+		return err
+	})
+	require.NoError(t, err)
+
+	// an asset recreated under its namespaced key before the migration is run is not overwritten
+	legacy = ledger.NewStub()
+	require.NoError(t, legacy.PutState("Car1", []byte(`{"AssetType":"Car","Car_ID":"Car1","Date_of_manufacture":"20190101","Misc":""}`)))
+	require.NoError(t, legacy.Commit())
+	_, err = migrate(adminClient, 100, "")
+	require.EqualError(t, err, "the Car Car1 is held under both its bare and namespaced keys")
+}
+
+func TestMigrateFuelcellRates(t *testing.T) {
+	ledger := simulator.NewLedger(time.Date(2020, time.March, 2, 9, 0, 0, 0, time.UTC))
+	contract := &chaincode.SmartContract{}
+	require.NoError(t, ledger.Submit(adminClient, contract.InitLedger))
+
+	// fuel cells recorded before rates were private, as the original InitLedger wrote them, re-keyed by
+	// MigrateAssetKeys
+	legacy := ledger.NewStub()
+	key := compositeKey(t, "Fuelcell", "FuelCell9")
+	require.NoError(t, legacy.PutState(key, []byte(`{"AssetType":"Fuelcell","Fuelcell_ID":"FuelCell9","Supplier_ID":"Supplier1",`+
+		`"Base_rate":1,"Distance_rate":0.2,"energy_rate":1,"Currency":"GBP","Date_Received":20200122,"Date_Returned":0,"Misc":""}`)))
+	poundsKey := compositeKey(t, "Fuelcell", "FuelCell8")
+	require.NoError(t, legacy.PutState(poundsKey, []byte(`{"AssetType":"Fuelcell","Fuelcell_ID":"FuelCell8","Supplier_ID":"Supplier1",`+
+		`"Base_rate":0.5,"Distance_rate":0.1,"energy_rate":1,"Currency":"Pounds","Date_Received":20210122,"Date_Returned":0,"Misc":""}`)))
+	require.NoError(t, legacy.Commit())
+
+	err := ledger.Submit(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+		_, err := contract.MigrateFuelcellRates(ctx)
+		return err
+	})
+	require.Error(t, err, "only the billing admin may migrate the ledger")
+
+	for _, expected := range []int{2, 0} {
+		err = ledger.Submit(adminClient, func(ctx contractapi.TransactionContextInterface) error {
+			migrated, err := contract.MigrateFuelcellRates(ctx)
+			require.Equal(t, expected, migrated)
+			return err
+		})
+		require.NoError(t, err)
+	}
+	require.JSONEq(t, `{"AssetType":"Fuelcell","Fuelcell_ID":"FuelCell9","Supplier_ID":"Supplier1","Currency":"GBP","Date_Received":20200122,"Date_Returned":0,"Misc":"","Last_submitted_by":"Org1MSP"}`,
+		string(ledger.State(key)))
+	require.JSONEq(t, `{"AssetType":"Fuelcell","Fuelcell_ID":"FuelCell8","Supplier_ID":"Supplier1","Currency":"GBP","Date_Received":20210122,"Date_Returned":0,"Misc":"","Last_submitted_by":"Org1MSP"}`,
+		string(ledger.State(poundsKey)))
+}
@@ -0,0 +1,272 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Money handling for billing. Rates and amounts are exact decimals held as strings on the ledger so
+// every peer computes the same bill; arithmetic is done with math/big and only rounded once per
+// line (each journey and the base charge) using the rounding policy of the bill's currency.
+
+// Rate is an exact, non negative decimal tariff such as "0.2", in units of the fuel cell's currency.
+// Ledgers written before rates were exact hold them as JSON numbers, which are still accepted.
+type Rate string
+
+// Money is an exact decimal amount rounded to the minor unit of its currency, such as "31.50"
+type Money string
+
+type roundingMode int
+
+const (
+	roundHalfUp   roundingMode = iota // halves round away from zero
+	roundHalfEven                     // halves round to the even neighbour (banker's rounding)
+)
+
+// currencyPolicy describes how amounts in a currency are rounded
+type currencyPolicy struct {
+	minorDigits int // number of decimal places in the currency's minor unit
+	rounding    roundingMode
+}
+
+// currencyPolicies lists the ISO 4217 currencies bills can be raised in
+var currencyPolicies = map[string]currencyPolicy{
+	"GBP": {minorDigits: 2, rounding: roundHalfUp},
+	"EUR": {minorDigits: 2, rounding: roundHalfEven},
+	"USD": {minorDigits: 2, rounding: roundHalfEven},
+	"JPY": {minorDigits: 0, rounding: roundHalfUp},
+}
+
+// currencyAliases maps the free text currencies found on older ledgers to their ISO 4217 code
+var currencyAliases = map[string]string{
+	"£":       "GBP",
+	"Pounds":  "GBP",
+	"€":       "EUR",
+	"Euros":   "EUR",
+	"$":       "USD",
+	"Dollars": "USD",
+}
+
+var decimalPattern = regexp.MustCompile(`^-?[0-9]+(\.[0-9]+)?$`)
+
+// currencyCode returns the ISO 4217 code and rounding policy for a currency or one of its legacy aliases
+func currencyCode(currency string) (string, currencyPolicy, error) {
+	if code, ok := currencyAliases[currency]; ok {
+		currency = code
+	}
+	policy, ok := currencyPolicies[currency]
+	if !ok {
+		return "", currencyPolicy{}, fmt.Errorf("the currency %s is not supported", currency)
+	}
+	return currency, policy, nil
+}
+
+// ParseRate checks that s is a non negative decimal and returns it in canonical form
+func ParseRate(s string) (Rate, error) {
+	r, err := parseDecimal(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid rate %q: %v", s, err)
+	}
+	if r.Sign() < 0 {
+		return "", fmt.Errorf("invalid rate %q: must not be negative", s)
+	}
+	return Rate(formatDecimal(r)), nil
+}
+
+// Rat returns the exact value of the rate
+func (r Rate) Rat() (*big.Rat, error) {
+	return parseDecimal(string(r))
+}
+
+// UnmarshalJSON accepts a rate written either as a JSON string or as a legacy JSON number. An empty
+// string is a rate that does not apply, such as the Energy_rate of a line of base days.
+func (r *Rate) UnmarshalJSON(data []byte) error {
+	if string(data) == `""` {
+		*r = ""
+		return nil
+	}
+	s, err := decimalFromJSON(data)
+	if err != nil {
+		return err
+	}
+	*r = Rate(s)
+	return nil
+}
+
+// ParseMoney checks that s is a decimal with no more places than the currency's minor unit allows
+func ParseMoney(s string, currency string) (Money, error) {
+	_, policy, err := currencyCode(currency)
+	if err != nil {
+		return "", err
+	}
+	r, err := parseDecimal(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid amount %q: %v", s, err)
+	}
+	minor := new(big.Rat).Mul(r, big.NewRat(pow10(policy.minorDigits), 1))
+	if !minor.IsInt() {
+		return "", fmt.Errorf("invalid amount %q: %s has %d decimal places", s, currency, policy.minorDigits)
+	}
+	if !minor.Num().IsInt64() {
+		return "", fmt.Errorf("invalid amount %q: too large", s)
+	}
+	return moneyFromMinor(minor.Num().Int64(), policy), nil
+}
+
+// Rat returns the exact value of the amount
+func (m Money) Rat() (*big.Rat, error) {
+	return parseDecimal(string(m))
+}
+
+// UnmarshalJSON accepts an amount written either as a JSON string or as a legacy JSON number. An
+// empty string is a cost that does not apply, such as the Energy_cost of a line of base days.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	if string(data) == `""` {
+		*m = ""
+		return nil
+	}
+	s, err := decimalFromJSON(data)
+	if err != nil {
+		return err
+	}
+	*m = Money(s)
+	return nil
+}
+
+// roundToMinor rounds an exact amount to a whole number of minor units using the currency's policy,
+// returning an error if that number does not fit in an int64
+func (p currencyPolicy) roundToMinor(amount *big.Rat) (int64, error) {
+	scaled := new(big.Rat).Mul(amount, big.NewRat(pow10(p.minorDigits), 1))
+	quo, rem := new(big.Int).QuoRem(scaled.Num(), scaled.Denom(), new(big.Int))
+	// compare twice the remainder with the denominator to decide which way the fraction rounds
+	twiceRem := new(big.Int).Abs(rem)
+	twiceRem.Lsh(twiceRem, 1)
+	cmp := twiceRem.Cmp(scaled.Denom())
+	if cmp > 0 || (cmp == 0 && (p.rounding == roundHalfUp || quo.Bit(0) == 1)) {
+		if scaled.Sign() < 0 {
+			quo.Sub(quo, big.NewInt(1))
+		} else {
+			quo.Add(quo, big.NewInt(1))
+		}
+	}
+	if !quo.IsInt64() {
+		return 0, fmt.Errorf("the amount %s is too large", amount.FloatString(p.minorDigits))
+	}
+	return quo.Int64(), nil
+}
+
+// addMinor adds two whole numbers of minor units, returning an error if the sum does not fit in an int64
+func addMinor(a int64, b int64) (int64, error) {
+	sum := a + b
+	if (b > 0 && sum < a) || (b < 0 && sum > a) {
+		return 0, fmt.Errorf("the amount is too large")
+	}
+	return sum, nil
+}
+
+// moneyFromMinor formats a whole number of minor units as an amount, eg 3150 pence as "31.50"
+func moneyFromMinor(minor int64, p currencyPolicy) Money {
+	sign := ""
+	if minor < 0 {
+		sign = "-"
+		minor = -minor
+	}
+	digits := strconv.FormatInt(minor, 10)
+	if p.minorDigits == 0 {
+		return Money(sign + digits)
+	}
+	if len(digits) <= p.minorDigits {
+		digits = strings.Repeat("0", p.minorDigits-len(digits)+1) + digits
+	}
+	split := len(digits) - p.minorDigits
+	return Money(sign + digits[:split] + "." + digits[split:])
+}
+
+// parseDecimal parses a plain decimal such as "-12.5"; fractions and exponents are rejected
+func parseDecimal(s string) (*big.Rat, error) {
+	if !decimalPattern.MatchString(s) {
+		return nil, fmt.Errorf("not a decimal number")
+	}
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil, fmt.Errorf("not a decimal number")
+	}
+	return r, nil
+}
+
+// decimalFromJSON returns the canonical decimal text of a JSON string or number
+func decimalFromJSON(data []byte) (string, error) {
+	var text string
+	if len(data) > 0 && data[0] == '"' {
+		err := json.Unmarshal(data, &text)
+		if err != nil {
+			return "", err
+		}
+		_, err = parseDecimal(text)
+		if err != nil {
+			return "", fmt.Errorf("invalid decimal %q: %v", text, err)
+		}
+		return text, nil
+	}
+	var number json.Number
+	err := json.Unmarshal(data, &number)
+	if err != nil {
+		return "", err
+	}
+	r, ok := new(big.Rat).SetString(number.String()) // JSON numbers may use exponents but always terminate
+	if !ok {
+		return "", fmt.Errorf("invalid decimal %s", number)
+	}
+	return formatDecimal(r), nil
+}
+
+// formatDecimal writes a terminating rational as the shortest exact decimal, eg 1/5 as "0.2"
+func formatDecimal(r *big.Rat) string {
+	places := 0
+	scaled := new(big.Rat).Set(r)
+	ten := big.NewRat(10, 1)
+	for !scaled.IsInt() {
+		scaled.Mul(scaled, ten)
+		places++
+	}
+	return r.FloatString(places)
+}
+
+func pow10(n int) int64 {
+	result := int64(1)
+	for i := 0; i < n; i++ {
+		result *= 10
+	}
+	return result
+}
+
+// journeyCharges returns the exact distance and energy charges for a journey:
+// Distance * Distance_rate and H2_used * Efficiency * Energy_rate
+func journeyCharges(journey *JourneyData, distanceRate Rate, energyRate Rate) (*big.Rat, *big.Rat, error) {
+	perDistance, err := distanceRate.Rat()
+	if err != nil {
+		return nil, nil, err
+	}
+	perEnergy, err := energyRate.Rat()
+	if err != nil {
+		return nil, nil, err
+	}
+	efficiency, err := journeyEfficiency(journey)
+	if err != nil {
+		return nil, nil, err
+	}
+	distanceCost := new(big.Rat).Mul(big.NewRat(int64(journey.Distance), 1), perDistance)
+	energyCost := new(big.Rat).Mul(big.NewRat(int64(journey.H2_used), 1), efficiency)
+	energyCost.Mul(energyCost, perEnergy)
+	return distanceCost, energyCost, nil
+}
+
+// journeyEfficiency returns the Efficiency of a journey as the exact decimal that was submitted
+func journeyEfficiency(journey *JourneyData) (*big.Rat, error) {
+	// the shortest decimal that round trips the float32 is what was submitted, eg 0.3 rather than 0.30000001192...
+	return parseDecimal(strconv.FormatFloat(float64(journey.Efficiency), 'f', -1, 32))
+}
@@ -0,0 +1,122 @@
+package chaincode
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundToMinor(t *testing.T) {
+	tests := []struct {
+		currency string
+		amount   string
+		minor    int64
+		err      string
+	}{
+		{"GBP", "1.004", 100, ""},
+		{"GBP", "1.005", 101, ""}, // half up
+		{"GBP", "1.015", 102, ""},
+		{"GBP", "-1.005", -101, ""}, // halves round away from zero
+		{"EUR", "1.005", 100, ""},   // half even
+		{"EUR", "1.015", 102, ""},
+		{"EUR", "1.0051", 101, ""},
+		{"EUR", "-1.025", -102, ""},
+		{"USD", "2.675", 268, ""},
+		{"JPY", "12.5", 13, ""},
+		{"JPY", "12.4", 12, ""},
+		{"JPY", "-12.5", -13, ""},
+		{"GBP", "0", 0, ""},
+		{"GBP", "92233720368547758.07", 9223372036854775807, ""},
+		{"GBP", "92233720368547758.075", 0, "the amount 92233720368547758.08 is too large"},
+		{"JPY", "-9223372036854775809", 0, "the amount -9223372036854775809 is too large"},
+	}
+	for _, test := range tests {
+		t.Run(test.currency+" "+test.amount, func(t *testing.T) {
+			_, policy, err := currencyCode(test.currency)
+			require.NoError(t, err)
+			amount, ok := new(big.Rat).SetString(test.amount)
+			require.True(t, ok)
+			minor, err := policy.roundToMinor(amount)
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.minor, minor)
+		})
+	}
+}
+
+func TestAddMinor(t *testing.T) {
+	sum, err := addMinor(math.MaxInt64-1, 1)
+	require.NoError(t, err)
+	require.Equal(t, int64(math.MaxInt64), sum)
+	_, err = addMinor(math.MaxInt64, 1)
+	require.EqualError(t, err, "the amount is too large")
+	_, err = addMinor(math.MinInt64, -1)
+	require.EqualError(t, err, "the amount is too large")
+}
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		money    Money
+		err      string
+	}{
+		{"31.5", "GBP", "31.50", ""},
+		{"31.50", "EUR", "31.50", ""},
+		{"0.07", "USD", "0.07", ""},
+		{"-4.20", "GBP", "-4.20", ""},
+		{"1500", "JPY", "1500", ""},
+		{"12", "Pounds", "12.00", ""},
+		{"12", "€", "12.00", ""},
+		{"1.005", "GBP", "", `invalid amount "1.005": GBP has 2 decimal places`},
+		{"15.5", "JPY", "", `invalid amount "15.5": JPY has 0 decimal places`},
+		{"1e3", "GBP", "", `invalid amount "1e3": not a decimal number`},
+		{"", "GBP", "", `invalid amount "": not a decimal number`},
+		{"1", "XYZ", "", "the currency XYZ is not supported"},
+		{"92233720368547758.08", "GBP", "", `invalid amount "92233720368547758.08": too large`},
+	}
+	for _, test := range tests {
+		t.Run(test.currency+" "+test.amount, func(t *testing.T) {
+			money, err := ParseMoney(test.amount, test.currency)
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.money, money)
+		})
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		rate     string
+		expected Rate
+		err      string
+	}{
+		{"0.2", "0.2", ""},
+		{"0.20", "0.2", ""},
+		{"1.50", "1.5", ""},
+		{"0", "0", ""},
+		{"0.000125", "0.000125", ""},
+		{"-0.2", "", `invalid rate "-0.2": must not be negative`},
+		{"1/5", "", `invalid rate "1/5": not a decimal number`},
+		{".5", "", `invalid rate ".5": not a decimal number`},
+	}
+	for _, test := range tests {
+		t.Run(test.rate, func(t *testing.T) {
+			rate, err := ParseRate(test.rate)
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, test.expected, rate)
+		})
+	}
+}
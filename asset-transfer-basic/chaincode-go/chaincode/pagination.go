@@ -0,0 +1,190 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Paginated variants of the GetAll* queries. Each returns one page of records with the bookmark to
+// pass to the next call, which is empty once the last page has been returned. Paginated queries are
+// only valid for read only transactions, so these must be evaluated rather than submitted.
+
+// maxPageSize caps the records returned by one call so a page stays well inside gRPC message limits;
+// a larger page size returns pages of maxPageSize
+const maxPageSize = 200
+
+// JourneyPage is one page of journeys and the bookmark for the next
+type JourneyPage struct {
+	Records             []*JourneyData `json:"records"`
+	FetchedRecordsCount int32          `json:"fetchedRecordsCount"`
+	Bookmark            string         `json:"bookmark"`
+}
+
+// CarPage is one page of cars and the bookmark for the next
+type CarPage struct {
+	Records             []*Car `json:"records"`
+	FetchedRecordsCount int32  `json:"fetchedRecordsCount"`
+	Bookmark            string `json:"bookmark"`
+}
+
+// CarComponentPage is one page of car components and the bookmark for the next
+type CarComponentPage struct {
+	Records             []*CarComponent `json:"records"`
+	FetchedRecordsCount int32           `json:"fetchedRecordsCount"`
+	Bookmark            string          `json:"bookmark"`
+}
+
+// SupplierPage is one page of suppliers and the bookmark for the next
+type SupplierPage struct {
+	Records             []*Supplier `json:"records"`
+	FetchedRecordsCount int32       `json:"fetchedRecordsCount"`
+	Bookmark            string      `json:"bookmark"`
+}
+
+// FuelcellPage is one page of fuel cells and the bookmark for the next
+type FuelcellPage struct {
+	Records             []*FuelcellData `json:"records"`
+	FetchedRecordsCount int32           `json:"fetchedRecordsCount"`
+	Bookmark            string          `json:"bookmark"`
+}
+
+// BillPage is one page of bills and the bookmark for the next
+type BillPage struct {
+	Records             []*Bill `json:"records"`
+	FetchedRecordsCount int32   `json:"fetchedRecordsCount"`
+	Bookmark            string  `json:"bookmark"`
+}
+
+// GetAllJourneysWithPagination returns a page of at most pageSize journeys starting at bookmark
+func (s *SmartContract) GetAllJourneysWithPagination(ctx contractapi.TransactionContextInterface, pageSize int, bookmark string) (*JourneyPage, error) {
+	page := JourneyPage{Records: []*JourneyData{}}
+	var err error
+	page.FetchedRecordsCount, page.Bookmark, err = queryPage(ctx, selector{"AssetType": "Journey"}, pageSize, bookmark, func(value []byte) error {
+		var asset JourneyData
+		err := json.Unmarshal(value, &asset)
+		page.Records = append(page.Records, &asset)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// GetAllCarsWithPagination returns a page of at most pageSize cars starting at bookmark
+func (s *SmartContract) GetAllCarsWithPagination(ctx contractapi.TransactionContextInterface, pageSize int, bookmark string) (*CarPage, error) {
+	page := CarPage{Records: []*Car{}}
+	var err error
+	page.FetchedRecordsCount, page.Bookmark, err = queryPage(ctx, selector{"AssetType": "Car"}, pageSize, bookmark, func(value []byte) error {
+		var asset Car
+		err := json.Unmarshal(value, &asset)
+		page.Records = append(page.Records, &asset)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// GetAllCarComponentsWithPagination returns a page of at most pageSize car components starting at bookmark
+func (s *SmartContract) GetAllCarComponentsWithPagination(ctx contractapi.TransactionContextInterface, pageSize int, bookmark string) (*CarComponentPage, error) {
+	page := CarComponentPage{Records: []*CarComponent{}}
+	var err error
+	page.FetchedRecordsCount, page.Bookmark, err = queryPage(ctx, selector{"AssetType": "Car_Component"}, pageSize, bookmark, func(value []byte) error {
+		var asset CarComponent
+		err := json.Unmarshal(value, &asset)
+		page.Records = append(page.Records, &asset)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// GetAllSuppliersWithPagination returns a page of at most pageSize suppliers starting at bookmark
+func (s *SmartContract) GetAllSuppliersWithPagination(ctx contractapi.TransactionContextInterface, pageSize int, bookmark string) (*SupplierPage, error) {
+	page := SupplierPage{Records: []*Supplier{}}
+	var err error
+	page.FetchedRecordsCount, page.Bookmark, err = queryPage(ctx, selector{"AssetType": "Supplier"}, pageSize, bookmark, func(value []byte) error {
+		var asset Supplier
+		err := json.Unmarshal(value, &asset)
+		page.Records = append(page.Records, &asset)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// GetAllFuelcellsWithPagination returns a page of at most pageSize fuel cells starting at bookmark
+func (s *SmartContract) GetAllFuelcellsWithPagination(ctx contractapi.TransactionContextInterface, pageSize int, bookmark string) (*FuelcellPage, error) {
+	page := FuelcellPage{Records: []*FuelcellData{}}
+	var err error
+	page.FetchedRecordsCount, page.Bookmark, err = queryPage(ctx, selector{"AssetType": "Fuelcell"}, pageSize, bookmark, func(value []byte) error {
+		var asset FuelcellData
+		err := json.Unmarshal(value, &asset)
+		page.Records = append(page.Records, &asset)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// GetAllBillsWithPagination returns a page of at most pageSize bills starting at bookmark, from the
+// bills the submitting client may read
+func (s *SmartContract) GetAllBillsWithPagination(ctx contractapi.TransactionContextInterface, pageSize int, bookmark string) (*BillPage, error) {
+	query, err := s.billQuery(ctx)
+	if err != nil {
+		return nil, err
+	}
+	page := BillPage{Records: []*Bill{}}
+	page.FetchedRecordsCount, page.Bookmark, err = queryPage(ctx, query, pageSize, bookmark, func(value []byte) error {
+		var asset Bill
+		err := json.Unmarshal(value, &asset)
+		normalizeBill(&asset)
+		page.Records = append(page.Records, &asset)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// queryPage runs a paginated rich query for the assets matching the selector, passing each record to add,
+// and returns the number of records fetched and the bookmark for the next page
+func queryPage(ctx contractapi.TransactionContextInterface, query selector, pageSize int, bookmark string, add func(value []byte) error) (int32, string, error) {
+	if pageSize <= 0 {
+		return 0, "", fmt.Errorf("the page size must be at least 1")
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+	resultsIterator, responseMetadata, err := ctx.GetStub().GetQueryResultWithPagination(query.query(), int32(pageSize), bookmark)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resultsIterator.Close()
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return 0, "", err
+		}
+		err = add(queryResult.Value)
+		if err != nil {
+			return 0, "", err
+		}
+	}
+	nextBookmark := responseMetadata.Bookmark
+	if responseMetadata.FetchedRecordsCount < int32(pageSize) {
+		nextBookmark = "" // a short page is the last one
+	}
+	return responseMetadata.FetchedRecordsCount, nextBookmark, nil
+}
@@ -0,0 +1,53 @@
+package chaincode_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/simulator"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetAllCarsWithPagination(t *testing.T) {
+	ledger := simulator.NewLedger(time.Date(2020, time.March, 2, 9, 0, 0, 0, time.UTC))
+	contract := &chaincode.SmartContract{}
+	require.NoError(t, ledger.Submit(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+		for i := 1; i <= 205; i++ {
+			err := contract.CreateCar(ctx, fmt.Sprintf("Car%03d", i), "20200101", "")
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	page := func(pageSize int, bookmark string) (*chaincode.CarPage, error) {
+		var result *chaincode.CarPage
+		err := ledger.Evaluate(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+			var err error
+			result, err = contract.GetAllCarsWithPagination(ctx, pageSize, bookmark)
+			return err
+		})
+		return result, err
+	}
+
+	_, err := page(0, "")
+	require.EqualError(t, err, "the page size must be at least 1")
+
+	// a page size above the cap returns a page of 200
+	first, err := page(1000, "")
+	require.NoError(t, err)
+	require.Equal(t, int32(200), first.FetchedRecordsCount)
+	require.Len(t, first.Records, 200)
+	require.Equal(t, "Car001", first.Records[0].Car_ID)
+	require.NotEmpty(t, first.Bookmark)
+
+	// the bookmark resumes after the last car returned and the short last page has no bookmark
+	last, err := page(1000, first.Bookmark)
+	require.NoError(t, err)
+	require.Equal(t, int32(5), last.FetchedRecordsCount)
+	require.Equal(t, "Car201", last.Records[0].Car_ID)
+	require.Empty(t, last.Bookmark)
+}
@@ -0,0 +1,219 @@
+package chaincode_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/simulator"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	adminClient = &simulator.Client{ID: "admin", MSPID: "Org1MSP", Attributes: billingAdmin}
+	fleetClient = &simulator.Client{ID: "fleet", MSPID: "Org1MSP", Attributes: fleetOperator}
+)
+
+// TestBillingScenario seeds the ledger, records a journey and bills the first fuel cell twice,
+// committing each step before the next as a network would
+func TestBillingScenario(t *testing.T) {
+	ledger := simulator.NewLedger(time.Date(2020, time.March, 2, 9, 0, 0, 0, time.UTC))
+	contract := &chaincode.SmartContract{}
+
+	err := ledger.Submit(adminClient, contract.InitLedger)
+	require.NoError(t, err)
+
+	err = ledger.Submit(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.CreateJourney(ctx, "Journey7", "Car1", "Component1", "1500", "100", "10", 0.5, "Supplier1", "20200201")
+	})
+	require.NoError(t, err)
+	err = ledger.Submit(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.CreateJourney(ctx, "Journey8", "Car1", "Component1", "1550", "100", "10", 0.5, "Supplier1", "20200202")
+	})
+	require.EqualError(t, err, "the Odo_start 1550 is below the end of Journey Journey7 at 1600")
+
+	// 39 days from receipt on 22 January at 1, Journey1 at 100 + 30, Journey2 at 200 + 75 and Journey7 at 20 + 5
+	err = ledger.Submit(adminClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.GenerateBill(ctx, "Bill1", "FuelCell1", "20200101", "20200229")
+	})
+	require.NoError(t, err)
+	bill := readBill(t, ledger, "Bill1")
+	require.Equal(t, chaincode.Money("469.00"), bill.Amount)
+	require.Equal(t, 20200101, bill.Date_from)
+	require.Equal(t, 20200302, bill.Status_date)
+	for _, journeyID := range []string{"Journey1", "Journey2", "Journey7"} {
+		var journey chaincode.JourneyData
+		require.NoError(t, json.Unmarshal(ledger.State(compositeKey(t, "Journey", journeyID)), &journey))
+		require.True(t, journey.Billed, journeyID)
+	}
+	events := ledger.Events()
+	require.Equal(t, chaincode.EventBillIssued, events[len(events)-1].EventName)
+
+	err = ledger.Submit(adminClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.GenerateBill(ctx, "Bill2", "FuelCell1", "20200215", "20200315")
+	})
+	require.EqualError(t, err, "this bill would overlap the time frame 20200101-20200229 covered by bill Bill1")
+	require.Nil(t, ledger.State(compositeKey(t, "Bill", "Bill2")))
+
+	err = ledger.Submit(adminClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.GenerateBill(ctx, "Bill2", "FuelCell1", "20200301", "20200331")
+	})
+	require.NoError(t, err)
+	require.Equal(t, chaincode.Money("31.00"), readBill(t, ledger, "Bill2").Amount)
+}
+
+// TestVoidAndRebill voids a wrong bill, records the journey it missed and bills the period again
+func TestVoidAndRebill(t *testing.T) {
+	ledger := simulator.NewLedger(time.Date(2020, time.March, 2, 9, 0, 0, 0, time.UTC))
+	contract := &chaincode.SmartContract{}
+	require.NoError(t, ledger.Submit(adminClient, contract.InitLedger))
+	err := ledger.Submit(adminClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.GenerateBill(ctx, "Bill1", "FuelCell1", "20200101", "20200229")
+	})
+	require.NoError(t, err)
+	require.Equal(t, chaincode.Money("444.00"), readBill(t, ledger, "Bill1").Amount)
+
+	voidBill := func(client *simulator.Client, billID string, reason string) error {
+		return ledger.Submit(client, func(ctx contractapi.TransactionContextInterface) error {
+			return contract.VoidBill(ctx, billID, reason)
+		})
+	}
+	require.Error(t, voidBill(fleetClient, "Bill1", "missed Journey7"), "only the billing admin may void bills")
+	require.EqualError(t, voidBill(adminClient, "Bill1", ""), "a reason is required to void bill Bill1")
+	require.NoError(t, voidBill(adminClient, "Bill1", "missed Journey7"))
+	bill := readBill(t, ledger, "Bill1")
+	require.Equal(t, chaincode.BillVoid, bill.Status)
+	require.Equal(t, "missed Journey7", bill.Reason)
+	events := ledger.Events()
+	require.Equal(t, "Bill"+chaincode.BillVoid, events[len(events)-1].EventName)
+	for _, journeyID := range []string{"Journey1", "Journey2"} {
+		var journey chaincode.JourneyData
+		require.NoError(t, json.Unmarshal(ledger.State(compositeKey(t, "Journey", journeyID)), &journey))
+		require.False(t, journey.Billed, journeyID)
+	}
+	require.EqualError(t, voidBill(adminClient, "Bill1", "again"), "the bill Bill1 cannot move from Void to Void")
+
+	err = ledger.Submit(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.CreateJourney(ctx, "Journey7", "Car1", "Component1", "1500", "100", "10", 0.5, "Supplier1", "20200201")
+	})
+	require.NoError(t, err)
+	err = ledger.Submit(adminClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.GenerateBill(ctx, "Bill3", "FuelCell1", "20200101", "20200229")
+	})
+	require.NoError(t, err)
+	require.Equal(t, chaincode.Money("469.00"), readBill(t, ledger, "Bill3").Amount)
+
+	// once the corrected bill has been paid it can no longer be voided
+	supplierClient := &simulator.Client{ID: "supplier", MSPID: "Org2MSP", Attributes: map[string]string{"billing.role": chaincode.RoleSupplier, "billing.supplier_id": "Supplier1"}}
+	require.NoError(t, ledger.Submit(fleetClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.AcknowledgeBill(ctx, "Bill3")
+	}))
+	require.NoError(t, ledger.Submit(supplierClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.MarkBillPaid(ctx, "Bill3")
+	}))
+	require.EqualError(t, voidBill(adminClient, "Bill3", "too late"), "the bill Bill3 cannot move from Paid to Void")
+}
+
+// TestVoidBillWithoutLineItems voids a bill entered by hand, which records no journeys to release
+func TestVoidBillWithoutLineItems(t *testing.T) {
+	ledger := simulator.NewLedger(time.Date(2020, time.March, 2, 9, 0, 0, 0, time.UTC))
+	contract := &chaincode.SmartContract{}
+	require.NoError(t, ledger.Submit(adminClient, contract.InitLedger))
+	require.NoError(t, ledger.Submit(adminClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.CreateNewBill(ctx, "Bill1", "Supplier1", "FuelCell1", "20200101", "20200229", "GBP", "31.50")
+	}))
+	require.NoError(t, ledger.Submit(adminClient, func(ctx contractapi.TransactionContextInterface) error {
+		return contract.VoidBill(ctx, "Bill1", "entered twice")
+	}))
+	bill := readBill(t, ledger, "Bill1")
+	require.Equal(t, chaincode.BillVoid, bill.Status)
+	require.Equal(t, "entered twice", bill.Reason)
+	require.Empty(t, bill.Journey_IDs)
+	var journey chaincode.JourneyData
+	require.NoError(t, json.Unmarshal(ledger.State(compositeKey(t, "Journey", "Journey1")), &journey))
+	require.False(t, journey.Billed)
+}
+
+// TestDisputeAndCredit disputes a bill, settles it with a partial credit note and pays the balance,
+// and credits a second bill in full so nothing is left to pay
+func TestDisputeAndCredit(t *testing.T) {
+	ledger := simulator.NewLedger(time.Date(2020, time.April, 1, 9, 0, 0, 0, time.UTC))
+	contract := &chaincode.SmartContract{}
+	require.NoError(t, ledger.Submit(adminClient, contract.InitLedger))
+	for _, bill := range []struct{ billID, startDate, endDate string }{
+		{"Bill1", "20200101", "20200229"},
+		{"Bill2", "20200301", "20200331"},
+	} {
+		require.NoError(t, ledger.Submit(adminClient, func(ctx contractapi.TransactionContextInterface) error {
+			return contract.GenerateBill(ctx, bill.billID, "FuelCell1", bill.startDate, bill.endDate)
+		}))
+	}
+	require.Equal(t, chaincode.Money("444.00"), readBill(t, ledger, "Bill1").Amount)
+	require.Equal(t, chaincode.Money("31.00"), readBill(t, ledger, "Bill2").Amount)
+
+	supplierClient := &simulator.Client{ID: "supplier", MSPID: "Org2MSP", Attributes: map[string]string{"billing.role": chaincode.RoleSupplier, "billing.supplier_id": "Supplier1"}}
+	dispute := func(client *simulator.Client, billID string, reason string) error {
+		return ledger.Submit(client, func(ctx contractapi.TransactionContextInterface) error {
+			return contract.DisputeBill(ctx, billID, reason)
+		})
+	}
+	credit := func(client *simulator.Client, billID string, creditNoteID string, amount string) error {
+		return ledger.Submit(client, func(ctx contractapi.TransactionContextInterface) error {
+			return contract.IssueCreditNote(ctx, billID, creditNoteID, amount, "odometer misread")
+		})
+	}
+	pay := func(billID string) error {
+		return ledger.Submit(supplierClient, func(ctx contractapi.TransactionContextInterface) error {
+			return contract.MarkBillPaid(ctx, billID)
+		})
+	}
+
+	require.EqualError(t, credit(supplierClient, "Bill1", "Credit1", "44.00"), "the bill Bill1 is Issued, only disputed bills can be credited")
+	require.Error(t, dispute(supplierClient, "Bill1", "odometer misread"), "only the fleet operator may dispute bills")
+	require.EqualError(t, dispute(fleetClient, "Bill1", ""), "a reason is required to dispute bill Bill1")
+	require.NoError(t, dispute(fleetClient, "Bill1", "odometer misread"))
+	bill := readBill(t, ledger, "Bill1")
+	require.Equal(t, chaincode.BillDisputed, bill.Status)
+	require.Equal(t, "odometer misread", bill.Reason)
+	require.EqualError(t, pay("Bill1"), "the bill Bill1 cannot move from Disputed to Paid")
+
+	require.Error(t, credit(fleetClient, "Bill1", "Credit1", "44.00"), "only the supplier may credit its bills")
+	require.EqualError(t, credit(supplierClient, "Bill1", "Credit1", "444.01"), "the credit 444.01 must be more than nothing and no more than the bill amount 444.00")
+	require.EqualError(t, credit(supplierClient, "Bill1", "Credit1", "0"), "the credit 0.00 must be more than nothing and no more than the bill amount 444.00")
+	require.EqualError(t, credit(supplierClient, "Bill1", "Credit1", "44.005"), `invalid amount "44.005": GBP has 2 decimal places`)
+	require.NoError(t, credit(supplierClient, "Bill1", "Credit1", "44"))
+	bill = readBill(t, ledger, "Bill1")
+	require.Equal(t, chaincode.BillCredited, bill.Status)
+	require.Equal(t, "Credit1", bill.Credit_note)
+	var creditNote *chaincode.CreditNote
+	require.NoError(t, ledger.Evaluate(supplierClient, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		creditNote, err = contract.ReadCreditNote(ctx, "Credit1")
+		return err
+	}))
+	require.Equal(t, chaincode.Money("44.00"), creditNote.Amount)
+	require.Equal(t, 20200401, creditNote.Date_issued)
+
+	// the balance left after a partial credit is paid
+	require.NoError(t, pay("Bill1"))
+	require.Equal(t, chaincode.BillPaid, readBill(t, ledger, "Bill1").Status)
+
+	require.NoError(t, dispute(fleetClient, "Bill2", "not our car"))
+	require.EqualError(t, credit(supplierClient, "Bill2", "Credit1", "31.00"), "the asset Credit1 already exists")
+	require.NoError(t, credit(supplierClient, "Bill2", "Credit2", "31.00"))
+	require.EqualError(t, pay("Bill2"), "the bill Bill2 was credited in full so nothing is left to pay")
+}
+
+// readBill reads a bill as the billing admin
+func readBill(t *testing.T, ledger *simulator.Ledger, billID string) *chaincode.Bill {
+	var bill *chaincode.Bill
+	err := ledger.Evaluate(adminClient, func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		bill, err = (&chaincode.SmartContract{}).ReadBill(ctx, billID)
+		return err
+	})
+	require.NoError(t, err)
+	return bill
+}
@@ -0,0 +1,22 @@
+package chaincode
+
+import (
+	"encoding/json"
+)
+
+// selector is a CouchDB selector matching each field to exactly the given value. Queries are built
+// from it rather than by formatting IDs into a JSON string, so a quote or brace in an ID can never
+// change the shape of the query. Write its field names as literals; the packaging test checks each
+// combination used has a CouchDB index.
+type selector map[string]string
+
+// query returns the rich query string for the selector
+func (s selector) query() string {
+	queryJSON, err := json.Marshal(struct {
+		Selector map[string]string `json:"selector"`
+	}{Selector: s})
+	if err != nil {
+		panic(err) // a map of strings always marshals
+	}
+	return string(queryJSON)
+}
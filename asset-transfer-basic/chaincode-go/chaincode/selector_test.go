@@ -0,0 +1,56 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode/mocks"
+	"github.com/stretchr/testify/require"
+)
+
+var hostileIDs = []string{
+	`FC1`,
+	`FC1"}}`,
+	`FC1","AssetType":"Bill`,
+	`FC1"},"Fuelcell_ID":{"$gt":"`,
+	`{"$regex":".*"}`,
+	`FC1\`,
+	`FC1\"`,
+	"FC1\n\t\u0000",
+	`</script>&`,
+	`ünïcödé`,
+}
+
+func TestSelectorQuery(t *testing.T) {
+	for _, id := range hostileIDs {
+		t.Run(id, func(t *testing.T) {
+			var query struct {
+				Selector map[string]interface{} `json:"selector"`
+			}
+			err := json.Unmarshal([]byte(selector{"AssetType": "Fuelcell", "Fuelcell_ID": id}.query()), &query)
+			require.NoError(t, err)
+			require.Equal(t, map[string]interface{}{"AssetType": "Fuelcell", "Fuelcell_ID": id}, query.Selector)
+		})
+	}
+}
+
+func TestGetFuelcellWithHostileID(t *testing.T) {
+	for _, id := range hostileIDs {
+		t.Run(id, func(t *testing.T) {
+			chaincodeStub := &mocks.ChaincodeStub{}
+			transactionContext := &mocks.TransactionContext{}
+			transactionContext.GetStubReturns(chaincodeStub)
+			chaincodeStub.GetQueryResultReturns(&mocks.StateQueryIterator{}, nil)
+
+			fuelcell, err := (&SmartContract{}).GetFuelcell(transactionContext, id)
+			require.NoError(t, err)
+			require.Nil(t, fuelcell)
+
+			var query struct {
+				Selector map[string]string `json:"selector"`
+			}
+			require.NoError(t, json.Unmarshal([]byte(chaincodeStub.GetQueryResultArgsForCall(0)), &query))
+			require.Equal(t, map[string]string{"AssetType": "Fuelcell", "Fuelcell_ID": id}, query.Selector)
+		})
+	}
+}
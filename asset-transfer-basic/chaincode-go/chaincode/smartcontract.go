@@ -20,6 +20,9 @@ type Car struct {
 	Car_ID              string `json:"Car_ID"`              // primary key for each car
 	Date_of_manufacture string `json:"Date_of_manufacture"` // all date formats YYYYMMDD
 	Misc                string `json:"Misc"`                // any other information needed about the car
+	Date_retired        int    `json:"Date_retired"`        // date the car left the fleet, 0 while in service
+	// MSP of the client whose transaction last wrote the asset, see history.go
+	Last_submitted_by string `json:"Last_submitted_by,omitempty" metadata:",optional"`
 }
 type CarComponent struct {
 	AssetType        string `json:"AssetType"`
@@ -28,25 +31,30 @@ type CarComponent struct {
 	Fuelcell_ID      string ` json:"Fuelcell_ID"`     // Cost per distance unit of asset in use
 	Date_added       int    `json:"Date_added"`       // eg hydrogen, fuel cell, motor etc any component in car you want
 	Date_removed     int    `json:"Date_removed"`     // Name of a Supplier would be expanded in more developed implementation
+
+	Last_submitted_by string `json:"Last_submitted_by,omitempty" metadata:",optional"`
 }
 type Supplier struct {
 	AssetType     string `json:"AssetType"`
 	Supplier_ID   string `json:"Supplier_ID"` // Has the cost incurred from this Journey been paid
 	Supplier_name string `json:"Supplier_name"`
-	Misc          string `json:"Misc"` //other info not processed
+	MSP_ID        string `json:"MSP_ID"`       // organisation whose clients act for the supplier, eg issuing credit notes
+	Misc          string `json:"Misc"`         //other info not processed
+	Date_retired  int    `json:"Date_retired"` // date the supplier stopped supplying fuel cells, 0 while active
+
+	Last_submitted_by string `json:"Last_submitted_by,omitempty" metadata:",optional"`
 }
 
 type FuelcellData struct {
-	AssetType     string  `json:"AssetType"`
-	Fuelcell_ID   string  `json:"Fuelcell_ID"` // foreign key for CarComponents
-	Supplier_ID   string  `json:"Supplier_ID"`
-	Base_rate     float32 `json:"Base_rate"`
-	Distance_rate float32 `json:"Distance_rate"`
-	Energy_rate   float32 `json:"energy_rate"` //rate of energy consumed per gramme h2 used (modulo efficiency) energy produced?
-	Currency      string  `json:"Currency"`
-	Date_Received int     `json:"Date_Received"`
-	Date_Returned int     `json:"Date_Returned"`
-	Misc          string  `json:"Misc"`
+	AssetType     string `json:"AssetType"`
+	Fuelcell_ID   string `json:"Fuelcell_ID"` // foreign key for CarComponents
+	Supplier_ID   string `json:"Supplier_ID"`
+	Currency      string `json:"Currency"` // ISO 4217 code, legacy ledgers may hold "Pounds" or "£"; rates are held privately, see tariffs.go
+	Date_Received int    `json:"Date_Received"`
+	Date_Returned int    `json:"Date_Returned"`
+	Misc          string `json:"Misc"`
+
+	Last_submitted_by string `json:"Last_submitted_by,omitempty" metadata:",optional"`
 }
 type JourneyData struct {
 	AssetType        string  `json:"AssetType"`
@@ -60,20 +68,36 @@ type JourneyData struct {
 	Journey_date     int     `json:"Journey_date"` // what date did this journey begin
 	Billed           bool    `json:"Billed"`
 	Misc             string  `json:"Misc"`
+
+	Last_submitted_by string `json:"Last_submitted_by,omitempty" metadata:",optional"`
 }
 type Bill struct {
-	AssetType   string  `json:"AssetType"`
-	Bill_ID     string  `json:"Bill_ID"`     // foreign key for CarComponents
-	Supplier_ID string  `json:"Supplier_ID"` // working off rounded to nearest int
-	Fuelcell_ID string  `json:"Fuelcell_ID"` // range of 0-1 how Efficienct the vehicle was used in fuel consumption calculation
-	Date_from   int     `json:"Date_from"`   // will be passed through to cost calculations as component name for fuel.
-	Date_to     int     `json:"Date_to"`     // primary key for the database
-	Currency    string  `json:"Currency"`    // what date did this journey begin
-	Amount      float32 `json:"Amount"`      // Has the cost incurred from this Journey been paid
+	AssetType   string `json:"AssetType"`
+	Bill_ID     string `json:"Bill_ID"`     // foreign key for CarComponents
+	Supplier_ID string `json:"Supplier_ID"` // working off rounded to nearest int
+	Fuelcell_ID string `json:"Fuelcell_ID"` // range of 0-1 how Efficienct the vehicle was used in fuel consumption calculation
+	Date_from   int    `json:"Date_from"`   // will be passed through to cost calculations as component name for fuel.
+	Date_to     int    `json:"Date_to"`     // primary key for the database
+	Currency    string `json:"Currency"`    // ISO 4217 code the Amount is rounded in
+	Amount      Money  `json:"Amount"`      // exact decimal string, eg "31.50"
+	Status      string `json:"Status"`      // Issued, Acknowledged, Paid, Disputed, Credited or Void; empty on older ledgers means Issued
+	Status_date int    `json:"Status_date"` // date of the last status change
+	Reason      string `json:"Reason"`      // why the bill was disputed, credited or voided
+	Credit_note string `json:"Credit_note"` // Credit_note_ID issued against this bill, if any
+	// journeys charged by the bill, released to be billed again if it is voided
+	Journey_IDs []string `json:"Journey_IDs,omitempty" metadata:",optional"`
+	// hex SHA-256 of the BillLines held in the tariff collection, explaining how the Amount was made up
+	Line_items_hash string `json:"Line_items_hash,omitempty" metadata:",optional"`
+
+	Last_submitted_by string `json:"Last_submitted_by,omitempty" metadata:",optional"`
 }
 
 // InitLedger adds a base set of all assets to the ledger allowing for basic testing
 func (s *SmartContract) InitLedger(ctx contractapi.TransactionContextInterface) error {
+	err := assertBillingAdmin(ctx)
+	if err != nil {
+		return err
+	}
 	Cars := []Car{
 		{AssetType: "Car", Car_ID: "Car1", Date_of_manufacture: "20200123", Misc: "the first test car"},
 		{AssetType: "Car", Car_ID: "Car2", Date_of_manufacture: "20210123", Misc: "the first car to swap cells"},
@@ -81,15 +105,10 @@ func (s *SmartContract) InitLedger(ctx contractapi.TransactionContextInterface)
 	}
 
 	for _, asset := range Cars {
-		assetJSON, err := json.Marshal(asset)
+		err := putAsset(ctx, asset)
 		if err != nil {
 			return err
 		}
-
-		err = ctx.GetStub().PutState(asset.Car_ID, assetJSON)
-		if err != nil {
-			return fmt.Errorf("failed to put to world state. %v", err)
-		}
 	}
 	CarComponents := []CarComponent{
 		{AssetType: "Car_Component", Car_Component_ID: "Component1", Car_ID: "Car1", Fuelcell_ID: "FuelCell1", Date_added: 20200123, Date_removed: 0}, // 0 signifies still in place
@@ -99,48 +118,44 @@ func (s *SmartContract) InitLedger(ctx contractapi.TransactionContextInterface)
 	}
 
 	for _, asset := range CarComponents {
-		assetJSON, err := json.Marshal(asset)
+		err := putAsset(ctx, asset)
 		if err != nil {
 			return err
 		}
-
-		err = ctx.GetStub().PutState(asset.Car_Component_ID, assetJSON)
-		if err != nil {
-			return fmt.Errorf("failed to put to world state. %v", err)
-		}
 	}
 	Suppliers := []Supplier{
-		{AssetType: "Supplier", Supplier_ID: "Supplier1", Supplier_name: "Hydrogen1", Misc: "Non preferred provider"},
-		{AssetType: "Supplier", Supplier_ID: "Supplier2", Supplier_name: "EfficentCells", Misc: "preferred provider"},
+		{AssetType: "Supplier", Supplier_ID: "Supplier1", Supplier_name: "Hydrogen1", MSP_ID: "Org2MSP", Misc: "Non preferred provider"},
+		{AssetType: "Supplier", Supplier_ID: "Supplier2", Supplier_name: "EfficentCells", MSP_ID: "Org2MSP", Misc: "preferred provider"},
 	}
 
 	for _, asset := range Suppliers {
-		assetJSON, err := json.Marshal(asset)
+		err := putAsset(ctx, asset)
 		if err != nil {
 			return err
 		}
-
-		err = ctx.GetStub().PutState((asset.Supplier_ID), assetJSON) ////////may cause errors
-		if err != nil {
-			return fmt.Errorf("failed to put to world state. %v", err)
-		}
 	}
 
 	Fuelcells := []FuelcellData{
-		{AssetType: "Fuelcell", Fuelcell_ID: "FuelCell1", Supplier_ID: "Supplier1", Base_rate: 1, Distance_rate: 0.2, Energy_rate: 1, Currency: "Pounds", Date_Received: 20200122, Date_Returned: 0, Misc: "Test cell not for production"},
-		{AssetType: "Fuelcell", Fuelcell_ID: "FuelCell2", Supplier_ID: "Supplier2", Base_rate: 0.5, Distance_rate: 0.1, Energy_rate: 1, Currency: "Pounds", Date_Received: 20210122, Date_Returned: 0},
-		{AssetType: "Fuelcell", Fuelcell_ID: "FuelCell3", Supplier_ID: "Supplier2", Base_rate: 0.8, Distance_rate: 0.2, Energy_rate: 1, Currency: "Pounds", Date_Received: 20210622, Date_Returned: 0},
+		{AssetType: "Fuelcell", Fuelcell_ID: "FuelCell1", Supplier_ID: "Supplier1", Currency: "GBP", Date_Received: 20200122, Date_Returned: 0, Misc: "Test cell not for production"},
+		{AssetType: "Fuelcell", Fuelcell_ID: "FuelCell2", Supplier_ID: "Supplier2", Currency: "GBP", Date_Received: 20210122, Date_Returned: 0},
+		{AssetType: "Fuelcell", Fuelcell_ID: "FuelCell3", Supplier_ID: "Supplier2", Currency: "GBP", Date_Received: 20210622, Date_Returned: 0},
+	}
+
+	// the rates of each fuel cell, in the same order; the seed rates are in this source so their salts need not be secret
+	FuelcellRates := []TariffRates{
+		{Base_rate: "1", Distance_rate: "0.2", Energy_rate: "1", Salt: "InitLedger FuelCell1"},
+		{Base_rate: "0.5", Distance_rate: "0.1", Energy_rate: "1", Salt: "InitLedger FuelCell2"},
+		{Base_rate: "0.8", Distance_rate: "0.2", Energy_rate: "1", Salt: "InitLedger FuelCell3"},
 	}
 
-	for _, asset := range Fuelcells {
-		assetJSON, err := json.Marshal(asset)
+	for i, asset := range Fuelcells {
+		err := putAsset(ctx, asset)
 		if err != nil {
 			return err
 		}
-
-		err = ctx.GetStub().PutState((asset.Fuelcell_ID), assetJSON) ////////may cause errors
+		err = putTariff(ctx, asset.Fuelcell_ID, asset.Date_Received, &FuelcellRates[i])
 		if err != nil {
-			return fmt.Errorf("failed to put to world state. %v", err)
+			return err
 		}
 	}
 
@@ -154,14 +169,10 @@ func (s *SmartContract) InitLedger(ctx contractapi.TransactionContextInterface)
 	}
 
 	for _, asset := range Journeys {
-		assetJSON, err := json.Marshal(asset)
+		err := putJourney(ctx, &asset)
 		if err != nil {
 			return err
 		}
-		err = ctx.GetStub().PutState((asset.Journey_ID), assetJSON) ////////may cause errors
-		if err != nil {
-			return fmt.Errorf("failed to put to world state. %v", err)
-		}
 	}
 
 	Bills := []Bill{ //example bill structures
@@ -170,125 +181,69 @@ func (s *SmartContract) InitLedger(ctx contractapi.TransactionContextInterface)
 	}
 
 	for _, asset := range Bills {
-		assetJSON, err := json.Marshal(asset)
+		err := putAsset(ctx, asset)
 		if err != nil {
 			return err
 		}
-		err = ctx.GetStub().PutState((asset.Bill_ID), assetJSON) ////////may cause errors
-		if err != nil {
-			return fmt.Errorf("failed to put to world state. %v", err)
-		}
 	}
 
 	return nil
 }
 func (s *SmartContract) GenerateBill(ctx contractapi.TransactionContextInterface, bill_ID string, fuelcell_ID string, startDate string, endDate string) error {
-	PastBills, err := s.GetAllBills(ctx)
-	if err != nil {
-		return err
-	}
-	fmt.Println(("GettingFuelcell"))
-	Fuelcell, err := s.GetFuelcell(ctx, fuelcell_ID)
+	err := assertBillingAdmin(ctx)
 	if err != nil {
 		return err
 	}
-	fmt.Println("GotFuelcell", Fuelcell.Fuelcell_ID)
-	intEndDate, err := strconv.Atoi(endDate)
+	_, err = s.issueBill(ctx, bill_ID, fuelcell_ID, startDate, endDate)
+	return err
+}
+
+// issueBill prices, writes and returns a bill, marking the journeys it charged for as billed
+func (s *SmartContract) issueBill(ctx contractapi.TransactionContextInterface, bill_ID string, fuelcell_ID string, startDate string, endDate string) (*Bill, error) {
+	// use the H2, effiency and distance from journey and Baserate, distance rate and energy rate from fuel cell to generate bill cost and create bill
+	bill, lines, billedJourneys, err := s.prepareBill(ctx, fuelcell_ID, startDate, endDate)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	intStartDate, err := strconv.Atoi(startDate)
+	bill.Bill_ID = bill_ID
+	err = s.writeBill(ctx, bill, lines, billedJourneys)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	for _, PastBill := range PastBills {
-		if PastBill.Supplier_ID == Fuelcell.Supplier_ID {
-			if intStartDate < PastBill.Date_from && PastBill.Date_from < intEndDate {
-				return fmt.Errorf("this bill would start encapulate another bills start time")
-			}
-			if intStartDate <= PastBill.Date_to && PastBill.Date_to <= intEndDate {
-				return fmt.Errorf("this bill would start encapulate another bills end time")
-
-			}
-			if PastBill.Date_from < intStartDate && intStartDate < PastBill.Date_to {
-				return fmt.Errorf("this bill would start inside a time frame covered by another bill")
-			}
-			if PastBill.Date_from <= intEndDate && intEndDate <= PastBill.Date_to {
-				return fmt.Errorf("this bill would end inside a time frame covered by another bill")
+	return bill, nil // successful completion
+}
 
-			}
+// writeBill issues a priced bill with its line items and marks the journeys it charged for as billed.
+// Every journey is checked before anything is written, but an error from the world state can still
+// leave the bill half written, so the caller must fail the transaction on any error.
+func (s *SmartContract) writeBill(ctx contractapi.TransactionContextInterface, bill *Bill, lines *BillLines, billedJourneys []*JourneyData) error {
+	for _, currentJourney := range billedJourneys {
+		currentJourney.Billed = true
+		err := currentJourney.validate()
+		if err != nil {
+			return fmt.Errorf("failed to mark Journey %s as billed error: %v", currentJourney.Journey_ID, err)
 		}
 	}
-
-	var TotalJourneysCost float32
-	if Fuelcell.Date_Received > intEndDate { // if fuelcell was recieved after the end date
-		return fmt.Errorf("fuelcell %s didn't exist in this time frame", fuelcell_ID)
-	}
-	if (Fuelcell.Date_Returned != 0) && (Fuelcell.Date_Returned < intStartDate) { // if the fuel cell has been returned and was returned before the start date
-		return fmt.Errorf("fuelcell %s had been returned before this time frame", fuelcell_ID)
-	}
-	// query to get carCompenents relevent, may return multiple if fuelcell moved inside billing time
-	fmt.Println(("Getting relevent car components"))
-	releventCarComponents, err := s.GetAllCarCompForFuelCellBetweenDates(ctx, Fuelcell.Fuelcell_ID, startDate, endDate)
-	fmt.Println(("got relevent car components"))
+	err := putBillLines(ctx, bill, lines)
 	if err != nil {
 		return err
 	}
-	// find relevent journey where car_Component is correct and journey is in range of car component installed
-	for _, currentCarComponent := range releventCarComponents {
-		fmt.Println("GetAllJourneysbetweendatesforCarComponent", currentCarComponent.Car_Component_ID)
-		releventJourneys, err := s.GetAllJourneysbetweendatesforCarComponent(ctx, currentCarComponent.Car_Component_ID, startDate, endDate)
-		if err != nil {
-			return err
-		}
-		fmt.Println("got", currentCarComponent.Car_Component_ID, "journeys")
-		for _, currentJourney := range releventJourneys {
-			fmt.Println("checking if current journey has been billed")
-			if !currentJourney.Billed {
-				fmt.Println("Journey wasnt Billed so doing maths on it")
-				JourneyCost := (float32(currentJourney.Distance) * Fuelcell.Distance_rate) + (float32(currentJourney.H2_used) * currentJourney.Efficiency * Fuelcell.Energy_rate)
-				TotalJourneysCost += JourneyCost
-			}
-		}
-
+	err = s.putNewBill(ctx, bill)
+	if err != nil {
+		return err
 	}
-	fmt.Println("calcuating final cost")
-	basecharge := ((float32(intEndDate-intStartDate) + 1) * Fuelcell.Base_rate) // plus one to account for 31st - 1 equalling 30 not 31 days
-	TotalCost := basecharge + TotalJourneysCost
-	// use the H2, effiency and distance from journey and Baserate, distance rate and energy rate from fuel cell to generate bill cost and create bill
-	fmt.Println("creating and submitting the bill")
-	error := s.CreateNewBill(ctx, bill_ID, Fuelcell.Supplier_ID, Fuelcell.Fuelcell_ID, startDate, endDate, "£", TotalCost)
-	if error != nil {
-		return error
-	}
-	fmt.Println("marking now billed journeys as so billed this does not mean paid")
-	for _, currentCarComponent := range releventCarComponents {
-		releventJourneys, err := s.GetAllJourneysbetweendatesforCarComponent(ctx, currentCarComponent.Car_Component_ID, startDate, endDate)
+	for _, currentJourney := range billedJourneys {
+		err = putAsset(ctx, currentJourney)
 		if err != nil {
-			return err
+			return fmt.Errorf("failed to mark Journey %s as billed error: %v", currentJourney.Journey_ID, err)
 		}
-		for _, currentJourney := range releventJourneys {
-			if !currentJourney.Billed {
-				currentJourney.Billed = true
-				BilledJourney, err := json.Marshal(currentJourney)
-				if err != nil {
-					return err
-				}
-				fmt.Println("submitting the updated journeys as billed")
-				err = ctx.GetStub().PutState(currentJourney.Journey_ID, BilledJourney)
-				if err != nil {
-					return fmt.Errorf("failed to mark Journey %s as billed error: %v", currentJourney.Journey_ID, err)
-				}
-			}
-		}
-
 	}
-	return error // successful completion
+	return nil
 }
 
 // Get functions used specifically for billing
 func (s *SmartContract) GetFuelcell(ctx contractapi.TransactionContextInterface, Fuelcell_ID string) (*FuelcellData, error) {
-	queryString := fmt.Sprintf(`{"selector":{"AssetType":"Fuelcell","Fuelcell_ID":"%s"}}`, Fuelcell_ID)
+	queryString := selector{"AssetType": "Fuelcell", "Fuelcell_ID": Fuelcell_ID}.query()
 	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString) // should only return one
 	if err != nil {
 		return nil, err
@@ -310,8 +265,15 @@ func (s *SmartContract) GetFuelcell(ctx contractapi.TransactionContextInterface,
 
 	return assets, nil
 }
+
+// GetAllCarCompForFuelCellBetweenDates returns the components the fuel cell was fitted as on any day of
+// the period. A component is not fitted on the day it was removed, see placementPeriod.
 func (s *SmartContract) GetAllCarCompForFuelCellBetweenDates(ctx contractapi.TransactionContextInterface, FuelcellID string, startDate string, endDate string) ([]*CarComponent, error) {
-	queryString := fmt.Sprintf(`{"selector":{"AssetType":"Car_Component","Fuelcell_ID":"%s"}}`, FuelcellID)
+	billingPeriod, err := newPeriod(startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	queryString := selector{"AssetType": "Car_Component", "Fuelcell_ID": FuelcellID}.query()
 	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
 	if err != nil {
 		return nil, err
@@ -328,22 +290,11 @@ func (s *SmartContract) GetAllCarCompForFuelCellBetweenDates(ctx contractapi.Tra
 		if err != nil {
 			return nil, err
 		}
-		intEndDate, err := strconv.Atoi(endDate)
+		placement, ok, err := placementPeriod(&asset)
 		if err != nil {
 			return nil, err
 		}
-		intStartDate, err := strconv.Atoi(startDate)
-		if err != nil {
-			return nil, err
-		}
-		fmt.Println("Successfully generated int versons of start string")
-		fmt.Println(asset.Car_Component_ID)
-		fmt.Println("(asset.Date_removed == 0" + strconv.FormatBool((asset.Date_removed == 0)))
-		fmt.Println("or")
-		fmt.Println("intStartDate <= asset.Date_removed", strconv.FormatBool(intStartDate <= asset.Date_removed), ")")
-		fmt.Println("and")
-		fmt.Println("asset.Date_added <= intEndDate", strconv.FormatBool(asset.Date_added <= intEndDate))
-		if (asset.Date_removed == 0 || intStartDate <= asset.Date_removed) && (asset.Date_added <= intEndDate) { // (if hasnt been removed or removed after start date) and was installed before the end date
+		if ok && placement.overlaps(billingPeriod) { // (if hasnt been removed or removed after start date) and was installed before the end date
 			assets = append(assets, &asset)
 		}
 	}
@@ -351,125 +302,139 @@ func (s *SmartContract) GetAllCarCompForFuelCellBetweenDates(ctx contractapi.Tra
 	return assets, nil
 }
 func (s *SmartContract) GetAllJourneysbetweendatesforCarComponent(ctx contractapi.TransactionContextInterface, Car_Component_ID string, startDate string, endDate string) ([]*JourneyData, error) {
-	queryString := fmt.Sprintf(`{"selector":{"AssetType":"Journey","Car_Component_ID":"%s"}}`, Car_Component_ID)
-	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
+	billingPeriod, err := newPeriod(startDate, endDate)
 	if err != nil {
 		return nil, err
 	}
-	defer resultsIterator.Close()
-	var assets []*JourneyData
-	for resultsIterator.HasNext() {
-		queryResult, err := resultsIterator.Next()
-		if err != nil {
-			return nil, err
-		}
-		var asset JourneyData
-		err = json.Unmarshal(queryResult.Value, &asset)
-		if err != nil {
-			return nil, err
-		}
-		intStartDate, err := strconv.Atoi(startDate)
-		if err != nil {
-			return nil, err
-		}
-		intEndDate, err := strconv.Atoi(endDate)
-		if err != nil {
-			return nil, err
-		}
-		if (intStartDate < asset.Journey_date) && (asset.Journey_date < intEndDate) { // as long as journey happened when component was present in those dates
-			assets = append(assets, &asset)
-		}
-	}
-
-	return assets, nil
+	// as long as journey happened when component was present in those dates
+	return journeysByIndex(ctx, journeyByComponentIndex, []string{Car_Component_ID}, &billingPeriod)
 }
 
 // create new asset functions:
+// CreateNewBill records a bill; amount is an exact decimal such as "31.50" in the major unit of Currency,
+// with no more decimal places than its minor unit allows
 func (s *SmartContract) CreateNewBill(ctx contractapi.TransactionContextInterface, Bill_ID string, Supplier_ID string,
-	Fuelcell_ID string, startDate string, endDate string, Currency string, amount float32) error {
-	exists, err := s.AssetExists(ctx, Bill_ID) // does the journey already exist
+	Fuelcell_ID string, startDate string, endDate string, Currency string, amount string) error {
+	err := assertBillingAdmin(ctx)
 	if err != nil {
 		return err
 	}
-	if exists {
-		return fmt.Errorf("the bill %s already exists", Bill_ID)
+	billingPeriod, err := newPeriod(startDate, endDate)
+	if err != nil {
+		return err
 	}
-	exists, err = s.AssetExists(ctx, Supplier_ID)
+	currency, _, err := currencyCode(Currency)
 	if err != nil {
 		return err
 	}
-	if !exists {
-		return fmt.Errorf("the Supplier %s Doesn't exist", Supplier_ID)
+	exactAmount, err := ParseMoney(amount, currency)
+	if err != nil {
+		return err
 	}
-	exists, err = s.AssetExists(ctx, Fuelcell_ID)
+	asset := Bill{
+		AssetType:   "Bill",
+		Bill_ID:     Bill_ID,
+		Supplier_ID: Supplier_ID,
+		Fuelcell_ID: Fuelcell_ID,
+		Date_from:   ledgerDate(billingPeriod.from),
+		Date_to:     ledgerDate(billingPeriod.to),
+		Currency:    currency,
+		Amount:      exactAmount,
+	}
+	return s.putNewBill(ctx, &asset)
+}
+
+// putNewBill checks a bill's ID is free and its supplier and fuel cell exist, then issues it
+func (s *SmartContract) putNewBill(ctx contractapi.TransactionContextInterface, bill *Bill) error {
+	exists, err := assetExists(ctx, "Bill", bill.Bill_ID) // does the bill already exist
 	if err != nil {
 		return err
 	}
-	if !exists {
-		return fmt.Errorf("the FuelCell_ID %s Doesn't exist", Fuelcell_ID)
+	if exists {
+		return fmt.Errorf("the bill %s already exists", bill.Bill_ID)
 	}
-	intEndDate, err := strconv.Atoi(endDate)
+	exists, err = assetExists(ctx, "Supplier", bill.Supplier_ID)
 	if err != nil {
 		return err
 	}
-	intStartDate, err := strconv.Atoi(startDate)
+	if !exists {
+		return fmt.Errorf("the Supplier %s Doesn't exist", bill.Supplier_ID)
+	}
+	exists, err = assetExists(ctx, "Fuelcell", bill.Fuelcell_ID)
 	if err != nil {
 		return err
 	}
-	asset := Bill{
-		AssetType:   "Bill",
-		Bill_ID:     Bill_ID,
-		Supplier_ID: Supplier_ID,
-		Fuelcell_ID: Fuelcell_ID,
-		Date_from:   intStartDate,
-		Date_to:     intEndDate,
-		Currency:    Currency,
-		Amount:      amount,
+	if !exists {
+		return fmt.Errorf("the FuelCell_ID %s Doesn't exist", bill.Fuelcell_ID)
 	}
-	assetJSON, err := json.Marshal(asset)
+	issued, err := txDate(ctx)
 	if err != nil {
 		return err
 	}
-
-	return ctx.GetStub().PutState(Bill_ID, assetJSON)
+	bill.Status = BillIssued
+	bill.Status_date = issued
+	err = putAsset(ctx, bill)
+	if err != nil {
+		return err
+	}
+	return emitEvent(ctx, EventBillIssued, newBillEvent(bill, "", ""))
 }
 
-// needs testing
+// CreateJourney records a journey after checking that the car, component and supplier exist, that the car
+// was in service and the component fitted to it on the journey date and that the telemetry is consistent
+// with the car's history
 func (s *SmartContract) CreateJourney(ctx contractapi.TransactionContextInterface, Journey_ID string, Car_ID string,
 	Car_Component_ID string, Odo_start string, Distance string, H2_used string, Efficiency float32,
 	FuelSupplier string, Date string) error {
-	exists, err := s.AssetExists(ctx, Journey_ID) // does the journey already exist
+	err := assertFleetOperator(ctx)
+	if err != nil {
+		return err
+	}
+	exists, err := assetExists(ctx, "Journey", Journey_ID) // does the journey already exist
 	if err != nil {
 		return err
 	}
 	if exists {
 		return fmt.Errorf("the asset %s already exists", Journey_ID)
 	}
+	intOdo_start, err := strconv.Atoi(Odo_start)
 	if err != nil {
 		return err
 	}
-	if !exists {
-		return fmt.Errorf("the Car %s Doesn't exist", Car_ID)
+	intDistance, err := strconv.Atoi(Distance)
+	if err != nil {
+		return err
 	}
+	intH2_used, err := strconv.Atoi(H2_used)
 	if err != nil {
 		return err
 	}
-	if !exists {
-		return fmt.Errorf("the FuelSupplier %s Doesn't exist", FuelSupplier)
+	intDate, err := parseLedgerDate(Date)
+	if err != nil {
+		return err
 	}
-	intOdo_start, err := strconv.Atoi(Odo_start)
+	if Efficiency < 0 || Efficiency > 1 {
+		return fmt.Errorf("the Efficiency %v must be in the range 0-1", Efficiency)
+	}
+	if intOdo_start < 0 || intDistance < 0 || intH2_used < 0 {
+		return fmt.Errorf("Odo_start, Distance and H2_used must not be negative")
+	}
+	component, err := s.fittedComponent(ctx, Car_ID, Car_Component_ID, intDate)
 	if err != nil {
 		return err
 	}
-	intDistance, err := strconv.Atoi(Distance)
+	_, err = s.ReadSupplier(ctx, FuelSupplier)
 	if err != nil {
 		return err
 	}
-	intH2_used, err := strconv.Atoi(H2_used)
+	fuelcell, err := s.ReadFuelcell(ctx, component.Fuelcell_ID)
 	if err != nil {
 		return err
 	}
-	intDate, err := strconv.Atoi(Date)
+	if fuelcell.Supplier_ID != FuelSupplier {
+		return fmt.Errorf("the Fuelcell %s fitted as %s is supplied by %s not %s", fuelcell.Fuelcell_ID, Car_Component_ID, fuelcell.Supplier_ID, FuelSupplier)
+	}
+	previousJourneys, err := s.GetAllJourneysofCar(ctx, Car_ID)
 	if err != nil {
 		return err
 	}
@@ -485,17 +450,58 @@ func (s *SmartContract) CreateJourney(ctx contractapi.TransactionContextInterfac
 		Journey_date:     intDate,
 		Billed:           false,
 	}
-	assetJSON, err := json.Marshal(asset)
+	err = checkOdometer(&asset, previousJourneys)
 	if err != nil {
 		return err
 	}
+	err = putJourney(ctx, &asset)
+	if err != nil {
+		return err
+	}
+	return emitEvent(ctx, EventJourneyCreated, newJourneyEvent(&asset))
+}
+
+// fittedComponent returns the car component after checking that the car exists and was in service on
+// the date, and that the component was fitted to it on the date
+func (s *SmartContract) fittedComponent(ctx contractapi.TransactionContextInterface, carID string, componentID string, date int) (*CarComponent, error) {
+	car, err := s.ReadCar(ctx, carID)
+	if err != nil {
+		return nil, err
+	}
+	err = checkCarInService(car, date)
+	if err != nil {
+		return nil, err
+	}
+	component, err := s.ReadCarComponent(ctx, componentID)
+	if err != nil {
+		return nil, err
+	}
+	if component.Car_ID != carID {
+		return nil, fmt.Errorf("the Car_Component %s belongs to Car %s not Car %s", componentID, component.Car_ID, carID)
+	}
+	if date < component.Date_added || (component.Date_removed != 0 && component.Date_removed <= date) {
+		return nil, fmt.Errorf("the Car_Component %s was not fitted to Car %s on %d", componentID, carID, date)
+	}
+	return component, nil
+}
 
-	return ctx.GetStub().PutState(Journey_ID, assetJSON)
+// checkOdometer returns an error if the journey starts below the end of any of the car's journeys
+// made on or before its date
+func checkOdometer(journey *JourneyData, previousJourneys []*JourneyData) error {
+	for _, previousJourney := range previousJourneys {
+		if previousJourney.Journey_date > journey.Journey_date {
+			continue
+		}
+		if journey.Odo_start < previousJourney.Odo_start+previousJourney.Distance { // odometer went backwards
+			return fmt.Errorf("the Odo_start %d is below the end of Journey %s at %d", journey.Odo_start, previousJourney.Journey_ID, previousJourney.Odo_start+previousJourney.Distance)
+		}
+	}
+	return nil
 }
 
 // Get all of a certain asset functions:
 func (s *SmartContract) GetAllJourneys(ctx contractapi.TransactionContextInterface) ([]*JourneyData, error) {
-	queryString := `{"selector":{"AssetType":"Journey"}}`
+	queryString := selector{"AssetType": "Journey"}.query()
 	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
 	if err != nil {
 		return nil, err
@@ -518,7 +524,7 @@ func (s *SmartContract) GetAllJourneys(ctx contractapi.TransactionContextInterfa
 	return assets, nil
 }
 func (s *SmartContract) GetAllCars(ctx contractapi.TransactionContextInterface) ([]*Car, error) {
-	queryString := `{"selector":{"AssetType":"Car"}}`
+	queryString := selector{"AssetType": "Car"}.query()
 	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
 	if err != nil {
 		return nil, err
@@ -541,7 +547,7 @@ func (s *SmartContract) GetAllCars(ctx contractapi.TransactionContextInterface)
 	return assets, nil
 }
 func (s *SmartContract) GetAllCarComponents(ctx contractapi.TransactionContextInterface) ([]*CarComponent, error) {
-	queryString := `{"selector":{"AssetType":"Car_Component"}}`
+	queryString := selector{"AssetType": "Car_Component"}.query()
 	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
 	if err != nil {
 		return nil, err
@@ -564,7 +570,7 @@ func (s *SmartContract) GetAllCarComponents(ctx contractapi.TransactionContextIn
 	return assets, nil
 }
 func (s *SmartContract) GetAllSuppliers(ctx contractapi.TransactionContextInterface) ([]*Supplier, error) {
-	queryString := `{"selector":{"AssetType":"Supplier"}}`
+	queryString := selector{"AssetType": "Supplier"}.query()
 	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
 	if err != nil {
 		return nil, err
@@ -587,7 +593,7 @@ func (s *SmartContract) GetAllSuppliers(ctx contractapi.TransactionContextInterf
 	return assets, nil
 }
 func (s *SmartContract) GetAllFuelcells(ctx contractapi.TransactionContextInterface) ([]*FuelcellData, error) {
-	queryString := `{"selector":{"AssetType":"Fuelcell"}}`
+	queryString := selector{"AssetType": "Fuelcell"}.query()
 	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
 	if err != nil {
 		return nil, err
@@ -609,9 +615,19 @@ func (s *SmartContract) GetAllFuelcells(ctx contractapi.TransactionContextInterf
 
 	return assets, nil
 }
+
+// GetAllBills returns every bill the submitting client may read; suppliers only see bills addressed to them
 func (s *SmartContract) GetAllBills(ctx contractapi.TransactionContextInterface) ([]*Bill, error) {
-	queryString := `{"selector":{"AssetType":"Bill"}}`
-	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
+	query, err := s.billQuery(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return billsMatching(ctx, query)
+}
+
+// billsMatching returns the bills matching the selector without checking the client may see them
+func billsMatching(ctx contractapi.TransactionContextInterface, query selector) ([]*Bill, error) {
+	resultsIterator, err := ctx.GetStub().GetQueryResult(query.query())
 	if err != nil {
 		return nil, err
 	}
@@ -627,6 +643,7 @@ func (s *SmartContract) GetAllBills(ctx contractapi.TransactionContextInterface)
 		if err != nil {
 			return nil, err
 		}
+		normalizeBill(&asset)
 		assets = append(assets, &asset)
 	}
 
@@ -634,9 +651,16 @@ func (s *SmartContract) GetAllBills(ctx contractapi.TransactionContextInterface)
 }
 
 // EXTRA FUNCTIONS PROVIDING FUNCTIONALLITY NOT CURRENTLY UTILISED#########################################################################################
-// function for if you wanted all Suppliers FuelCells
-func (s *SmartContract) GetAllSuppliersFuelCellsBetweenDates(ctx contractapi.TransactionContextInterface, FuelCell string, startDate string, endDate string) ([]*FuelcellData, error) {
-	queryString := fmt.Sprintf(`{"selector":{"AssetType":"Fuelcell","Supplier":"%s"}}`, FuelCell)
+// function for if you wanted all Suppliers FuelCells held at some point between the dates, or every supplier's if Supplier_ID is empty
+func (s *SmartContract) GetAllSuppliersFuelCellsBetweenDates(ctx contractapi.TransactionContextInterface, Supplier_ID string, startDate string, endDate string) ([]*FuelcellData, error) {
+	billingPeriod, err := newPeriod(startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	queryString := selector{"AssetType": "Fuelcell", "Supplier_ID": Supplier_ID}.query()
+	if Supplier_ID == "" {
+		queryString = selector{"AssetType": "Fuelcell"}.query()
+	}
 	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
 	if err != nil {
 		return nil, err
@@ -653,132 +677,37 @@ func (s *SmartContract) GetAllSuppliersFuelCellsBetweenDates(ctx contractapi.Tra
 		if err != nil {
 			return nil, err
 		}
-		intEndDate, err := strconv.Atoi(endDate)
-		if err != nil {
-			return nil, err
-		}
-		intStartDate, err := strconv.Atoi(startDate)
+		heldPeriod, err := ledgerPeriod(asset.Date_Received, asset.Date_Returned)
 		if err != nil {
 			return nil, err
 		}
-		if asset.Date_Received < intEndDate { // received before the end of the period
-			if (asset.Date_Returned == 0) || !(asset.Date_Returned < intStartDate) { // hasn't been returned or wasnt returned before the start of the period
-				assets = append(assets, &asset)
-			}
+		if heldPeriod.overlaps(billingPeriod) { // received before the end of the period and hasn't been returned before the start of it
+			assets = append(assets, &asset)
 		}
 	}
 
 	return assets, nil
 }
 func (s *SmartContract) GetJourneysbyCar(ctx contractapi.TransactionContextInterface, Car_ID string) ([]*JourneyData, error) {
-	// range query with empty string for startKey and endKey does an
-	// open-ended query of all assets in the chaincode namespace.
-	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
-	if err != nil {
-		return nil, err
-	}
-	defer resultsIterator.Close()
-
-	var assets []*JourneyData
-	for resultsIterator.HasNext() {
-		queryResponse, err := resultsIterator.Next()
-		if err != nil {
-			return nil, err
-		}
-
-		var asset JourneyData
-		err = json.Unmarshal(queryResponse.Value, &asset)
-		if err != nil {
-			return nil, err
-		}
-		if asset.Car_ID == Car_ID {
-			assets = append(assets, &asset)
-		}
-	}
-	return assets, nil
+	return journeysByIndex(ctx, journeyByCarIndex, []string{Car_ID}, nil)
 }
 func (s *SmartContract) GetAllJourneysofCar(ctx contractapi.TransactionContextInterface, term string) ([]*JourneyData, error) {
-	queryString := fmt.Sprintf(`{"selector":{"AssetType":"Journey","Car_ID":"%s"}}`, term)
-	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
-	if err != nil {
-		return nil, err
-	}
-	defer resultsIterator.Close()
-	var assets []*JourneyData
-	for resultsIterator.HasNext() {
-		queryResult, err := resultsIterator.Next()
-		if err != nil {
-			return nil, err
-		}
-		var asset JourneyData
-		err = json.Unmarshal(queryResult.Value, &asset)
-		if err != nil {
-			return nil, err
-		}
-		assets = append(assets, &asset)
-	}
-
-	return assets, nil
+	return journeysByIndex(ctx, journeyByCarIndex, []string{term}, nil)
 }
 func (s *SmartContract) GetAllJourneysbetweendates(ctx contractapi.TransactionContextInterface, startDate string, endDate string) ([]*JourneyData, error) {
-	queryString := `{"selector":{"AssetType":"Journey"}}`
-	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
+	billingPeriod, err := newPeriod(startDate, endDate)
 	if err != nil {
 		return nil, err
 	}
-	defer resultsIterator.Close()
-	var assets []*JourneyData
-	for resultsIterator.HasNext() {
-		queryResult, err := resultsIterator.Next()
-		if err != nil {
-			return nil, err
-		}
-		var asset JourneyData
-		err = json.Unmarshal(queryResult.Value, &asset)
-		if err != nil {
-			return nil, err
-		}
-		intStartDate, err := strconv.Atoi(startDate)
-		if err != nil {
-			return nil, err
-		}
-		intEndDate, err := strconv.Atoi(endDate)
-		if err != nil {
-			return nil, err
-		}
-		if (intStartDate < asset.Journey_date) && (asset.Journey_date < intEndDate) {
-			assets = append(assets, &asset)
-		}
-	}
-
-	return assets, nil
+	return journeysByIndex(ctx, journeyByCarIndex, []string{}, &billingPeriod)
 }
 
 // get contents of a specified journey
 func (s *SmartContract) ReadJourney(ctx contractapi.TransactionContextInterface, id string) (*JourneyData, error) {
-	assetJSON, err := ctx.GetStub().GetState(id)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read from world state: %v", err)
-	}
-	if assetJSON == nil {
-		return nil, fmt.Errorf("the asset %s does not exist", id)
-	}
-
 	var asset JourneyData
-	err = json.Unmarshal(assetJSON, &asset)
+	err := readAsset(ctx, id, "Journey", &asset)
 	if err != nil {
 		return nil, err
 	}
-
 	return &asset, nil
 }
-
-// AssetExists returns true when asset with given ID exists in world state
-func (s *SmartContract) AssetExists(ctx contractapi.TransactionContextInterface, Asset_ID string) (bool, error) {
-	assetJSON, err := ctx.GetStub().GetState(Asset_ID)
-	if err != nil {
-		return false, fmt.Errorf("failed to read from world state: %v", err)
-	}
-
-	return assetJSON != nil, nil
-}
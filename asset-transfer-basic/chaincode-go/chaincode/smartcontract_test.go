@@ -1,9 +1,14 @@
 package chaincode_test
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
@@ -11,6 +16,7 @@ import (
 	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode"
 	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode/mocks"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 //go:generate counterfeiter -o mocks/transaction.go -fake-name TransactionContext . transactionContext
@@ -28,157 +34,500 @@ type stateQueryIterator interface {
 	shim.StateQueryIteratorInterface
 }
 
-func TestInitLedger(t *testing.T) {
-	chaincodeStub := &mocks.ChaincodeStub{}
-	transactionContext := &mocks.TransactionContext{}
-	transactionContext.GetStubReturns(chaincodeStub)
+const tariffCollection = "fleetSupplierTariffs"
 
-	assetTransfer := chaincode.SmartContract{}
-	err := assetTransfer.InitLedger(transactionContext)
-	require.NoError(t, err)
+var (
+	billingAdmin  = map[string]string{"billing.role": chaincode.RoleBillingAdmin}
+	fleetOperator = map[string]string{"billing.role": chaincode.RoleFleetOperator}
+)
 
-	chaincodeStub.PutStateReturns(fmt.Errorf("failed inserting key"))
-	err = assetTransfer.InitLedger(transactionContext)
-	require.EqualError(t, err, "failed to put to world state. failed inserting key")
+// ledger is a world state served to the contract through the counterfeiter mocks. Writes are kept
+// apart from the state the transaction reads, as a peer does not let a transaction read its own writes.
+type ledger struct {
+	state         map[string][]byte
+	private       map[string][]byte
+	writes        map[string][]byte
+	privateWrites map[string][]byte
 }
 
-func TestCreateAsset(t *testing.T) {
-	chaincodeStub := &mocks.ChaincodeStub{}
-	transactionContext := &mocks.TransactionContext{}
-	transactionContext.GetStubReturns(chaincodeStub)
-
-	assetTransfer := chaincode.SmartContract{}
-	err := assetTransfer.CreateAsset(transactionContext, "", "", 0, "", 0)
-	require.NoError(t, err)
-
-	chaincodeStub.GetStateReturns([]byte{}, nil)
-	err = assetTransfer.CreateAsset(transactionContext, "asset1", "", 0, "", 0)
-	require.EqualError(t, err, "the asset asset1 already exists")
-
-	chaincodeStub.GetStateReturns(nil, fmt.Errorf("unable to retrieve asset"))
-	err = assetTransfer.CreateAsset(transactionContext, "asset1", "", 0, "", 0)
-	require.EqualError(t, err, "failed to read from world state: unable to retrieve asset")
+// newLedger returns a ledger holding the assets under their keys, with the composite key index
+// entries of each journey and the private rates of each tariff
+func newLedger(t *testing.T, assets map[string]interface{}, rates map[string]chaincode.TariffRates) *ledger {
+	l := &ledger{state: map[string][]byte{}, private: map[string][]byte{}, writes: map[string][]byte{}, privateWrites: map[string][]byte{}}
+	for id, asset := range assets {
+		assetJSON, err := json.Marshal(asset)
+		require.NoError(t, err)
+		var header struct {
+			AssetType string `json:"AssetType"`
+		}
+		require.NoError(t, json.Unmarshal(assetJSON, &header))
+		l.state[compositeKey(t, header.AssetType, id)] = assetJSON
+		if journey, ok := asset.(chaincode.JourneyData); ok {
+			date := fmt.Sprint(journey.Journey_date)
+			l.state[compositeKey(t, "journey~car~date", journey.Car_ID, date, id)] = []byte{0x00}
+			l.state[compositeKey(t, "journey~component~date", journey.Car_Component_ID, date, id)] = []byte{0x00}
+		}
+	}
+	for id, tariffRates := range rates {
+		ratesJSON, err := json.Marshal(tariffRates)
+		require.NoError(t, err)
+		l.private[id] = ratesJSON
+	}
+	return l
 }
 
-func TestReadAsset(t *testing.T) {
-	chaincodeStub := &mocks.ChaincodeStub{}
-	transactionContext := &mocks.TransactionContext{}
-	transactionContext.GetStubReturns(chaincodeStub)
-
-	expectedAsset := &chaincode.Asset{ID: "asset1"}
-	bytes, err := json.Marshal(expectedAsset)
+// tariff returns the public record of a tariff and its rates, with the hash of the rates set
+func tariff(t *testing.T, fuelcellID string, effectiveFrom int, rates chaincode.TariffRates) (chaincode.Tariff, chaincode.TariffRates) {
+	rates.AssetType = "Tariff_Rates"
+	rates.Tariff_ID = fmt.Sprintf("%s_tariff_%d", fuelcellID, effectiveFrom)
+	ratesJSON, err := json.Marshal(rates)
 	require.NoError(t, err)
+	hash := sha256.Sum256(ratesJSON)
+	return chaincode.Tariff{
+		AssetType:      "Tariff",
+		Tariff_ID:      rates.Tariff_ID,
+		Fuelcell_ID:    fuelcellID,
+		Effective_from: effectiveFrom,
+		Rates_hash:     hex.EncodeToString(hash[:]),
+	}, rates
+}
 
-	chaincodeStub.GetStateReturns(bytes, nil)
-	assetTransfer := chaincode.SmartContract{}
-	asset, err := assetTransfer.ReadAsset(transactionContext, "")
+// compositeKey builds a composite key, such as the world state key of an asset from its type and ID
+func compositeKey(t *testing.T, objectType string, attributes ...string) string {
+	key, err := shim.CreateCompositeKey(objectType, attributes)
 	require.NoError(t, err)
-	require.Equal(t, expectedAsset, asset)
-
-	chaincodeStub.GetStateReturns(nil, fmt.Errorf("unable to retrieve asset"))
-	_, err = assetTransfer.ReadAsset(transactionContext, "")
-	require.EqualError(t, err, "failed to read from world state: unable to retrieve asset")
-
-	chaincodeStub.GetStateReturns(nil, nil)
-	asset, err = assetTransfer.ReadAsset(transactionContext, "asset1")
-	require.EqualError(t, err, "the asset asset1 does not exist")
-	require.Nil(t, asset)
+	return key
 }
 
-func TestUpdateAsset(t *testing.T) {
+// context returns a transaction context on the ledger for a client of the organisation holding the
+// certificate attributes, on 1 March 2020
+func (l *ledger) context(mspID string, attributes map[string]string) (*mocks.TransactionContext, *mocks.ChaincodeStub) {
+	identity := &mocks.ClientIdentity{}
+	identity.GetMSPIDReturns(mspID, nil)
+	identity.GetAttributeValueStub = func(name string) (string, bool, error) {
+		value, found := attributes[name]
+		return value, found, nil
+	}
+	identity.AssertAttributeValueStub = func(name string, value string) error {
+		if actual, found := attributes[name]; !found || actual != value {
+			return fmt.Errorf("attribute %s is not %s", name, value)
+		}
+		return nil
+	}
+
 	chaincodeStub := &mocks.ChaincodeStub{}
+	chaincodeStub.GetTxTimestampReturns(timestamppb.New(time.Date(2020, time.March, 1, 12, 0, 0, 0, time.UTC)), nil)
+	chaincodeStub.GetStateStub = func(key string) ([]byte, error) {
+		return l.state[key], nil
+	}
+	chaincodeStub.PutStateStub = func(key string, value []byte) error {
+		l.writes[key] = value
+		return nil
+	}
+	chaincodeStub.GetPrivateDataStub = func(collection string, key string) ([]byte, error) {
+		if collection != tariffCollection {
+			return nil, fmt.Errorf("unknown collection %s", collection)
+		}
+		return l.private[key], nil
+	}
+	chaincodeStub.PutPrivateDataStub = func(collection string, key string, value []byte) error {
+		if collection != tariffCollection {
+			return fmt.Errorf("unknown collection %s", collection)
+		}
+		l.privateWrites[key] = value
+		return nil
+	}
+	chaincodeStub.CreateCompositeKeyStub = shim.CreateCompositeKey
+	chaincodeStub.SplitCompositeKeyStub = func(key string) (string, []string, error) {
+		parts := strings.Split(strings.TrimSuffix(strings.TrimPrefix(key, "\x00"), "\x00"), "\x00")
+		return parts[0], parts[1:], nil
+	}
+	chaincodeStub.GetStateByPartialCompositeKeyStub = func(objectType string, attributes []string) (shim.StateQueryIteratorInterface, error) {
+		prefix, err := shim.CreateCompositeKey(objectType, attributes)
+		if err != nil {
+			return nil, err
+		}
+		return l.iterator(func(key string, _ []byte) bool {
+			return strings.HasPrefix(key, prefix)
+		}), nil
+	}
+	chaincodeStub.GetQueryResultStub = func(query string) (shim.StateQueryIteratorInterface, error) {
+		var rich struct {
+			Selector map[string]string `json:"selector"`
+		}
+		err := json.Unmarshal([]byte(query), &rich)
+		if err != nil {
+			return nil, err
+		}
+		return l.iterator(func(key string, value []byte) bool {
+			var fields map[string]interface{}
+			if json.Unmarshal(value, &fields) != nil {
+				return false
+			}
+			for field, want := range rich.Selector {
+				if fmt.Sprint(fields[field]) != want {
+					return false
+				}
+			}
+			return true
+		}), nil
+	}
+
 	transactionContext := &mocks.TransactionContext{}
 	transactionContext.GetStubReturns(chaincodeStub)
-
-	expectedAsset := &chaincode.Asset{ID: "asset1"}
-	bytes, err := json.Marshal(expectedAsset)
-	require.NoError(t, err)
-
-	chaincodeStub.GetStateReturns(bytes, nil)
-	assetTransfer := chaincode.SmartContract{}
-	err = assetTransfer.UpdateAsset(transactionContext, "", "", 0, "", 0)
-	require.NoError(t, err)
-
-	chaincodeStub.GetStateReturns(nil, nil)
-	err = assetTransfer.UpdateAsset(transactionContext, "asset1", "", 0, "", 0)
-	require.EqualError(t, err, "the asset asset1 does not exist")
-
-	chaincodeStub.GetStateReturns(nil, fmt.Errorf("unable to retrieve asset"))
-	err = assetTransfer.UpdateAsset(transactionContext, "asset1", "", 0, "", 0)
-	require.EqualError(t, err, "failed to read from world state: unable to retrieve asset")
+	transactionContext.GetClientIdentityReturns(identity)
+	return transactionContext, chaincodeStub
 }
 
-func TestDeleteAsset(t *testing.T) {
-	chaincodeStub := &mocks.ChaincodeStub{}
-	transactionContext := &mocks.TransactionContext{}
-	transactionContext.GetStubReturns(chaincodeStub)
+// iterator returns an iterator over the state entries accepted by match, in key order
+func (l *ledger) iterator(match func(key string, value []byte) bool) *mocks.StateQueryIterator {
+	var keys []string
+	for key, value := range l.state {
+		if match(key, value) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	iterator := &mocks.StateQueryIterator{}
+	iterator.HasNextStub = func() bool {
+		return iterator.NextCallCount() < len(keys)
+	}
+	iterator.NextStub = func() (*queryresult.KV, error) {
+		key := keys[iterator.NextCallCount()-1]
+		return &queryresult.KV{Key: key, Value: l.state[key]}, nil
+	}
+	return iterator
+}
 
-	asset := &chaincode.Asset{ID: "asset1"}
-	bytes, err := json.Marshal(asset)
-	require.NoError(t, err)
+// billingAssets is a fuel cell fitted to Car1 as Component1 for the first half of January 2020 and to
+// Car2 as Component2 from then on, with a journey on each before and after the move of which Journey2
+// has already been billed, and a journey on Car2 in February
+func billingAssets(t *testing.T) (map[string]interface{}, map[string]chaincode.TariffRates) {
+	fuelcellTariff, rates := tariff(t, "FuelCell1", 20200101, chaincode.TariffRates{Base_rate: "1", Distance_rate: "0.2", Energy_rate: "1", Salt: "smartcontract test salt"})
+	assets := map[string]interface{}{
+		"Supplier1":              chaincode.Supplier{AssetType: "Supplier", Supplier_ID: "Supplier1", Supplier_name: "Hydrogen1", MSP_ID: "Org2MSP"},
+		"FuelCell1":              chaincode.FuelcellData{AssetType: "Fuelcell", Fuelcell_ID: "FuelCell1", Supplier_ID: "Supplier1", Currency: "GBP", Date_Received: 20200101},
+		"Car1":                   chaincode.Car{AssetType: "Car", Car_ID: "Car1", Date_of_manufacture: "20191201"},
+		"Car2":                   chaincode.Car{AssetType: "Car", Car_ID: "Car2", Date_of_manufacture: "20191201"},
+		"Component1":             chaincode.CarComponent{AssetType: "Car_Component", Car_Component_ID: "Component1", Car_ID: "Car1", Fuelcell_ID: "FuelCell1", Date_added: 20200101, Date_removed: 20200115},
+		"Component2":             chaincode.CarComponent{AssetType: "Car_Component", Car_Component_ID: "Component2", Car_ID: "Car2", Fuelcell_ID: "FuelCell1", Date_added: 20200115},
+		"Journey1":               chaincode.JourneyData{AssetType: "Journey", Journey_ID: "Journey1", Car_ID: "Car1", Car_Component_ID: "Component1", Odo_start: 0, Distance: 100, H2_used: 10, Efficiency: 0.5, Journey_date: 20200105},
+		"Journey2":               chaincode.JourneyData{AssetType: "Journey", Journey_ID: "Journey2", Car_ID: "Car1", Car_Component_ID: "Component1", Odo_start: 100, Distance: 50, H2_used: 10, Efficiency: 0.5, Journey_date: 20200110, Billed: true},
+		"Journey3":               chaincode.JourneyData{AssetType: "Journey", Journey_ID: "Journey3", Car_ID: "Car2", Car_Component_ID: "Component2", Odo_start: 0, Distance: 50, H2_used: 20, Efficiency: 0.25, Journey_date: 20200120},
+		"Journey4":               chaincode.JourneyData{AssetType: "Journey", Journey_ID: "Journey4", Car_ID: "Car2", Car_Component_ID: "Component2", Odo_start: 50, Distance: 10, H2_used: 0, Efficiency: 0.5, Journey_date: 20200205},
+		fuelcellTariff.Tariff_ID: fuelcellTariff,
+	}
+	return assets, map[string]chaincode.TariffRates{rates.Tariff_ID: rates}
+}
 
-	chaincodeStub.GetStateReturns(bytes, nil)
-	chaincodeStub.DelStateReturns(nil)
+func TestInitLedger(t *testing.T) {
+	l := newLedger(t, nil, nil)
 	assetTransfer := chaincode.SmartContract{}
-	err = assetTransfer.DeleteAsset(transactionContext, "")
+
+	transactionContext, chaincodeStub := l.context("Org1MSP", billingAdmin)
+	err := assetTransfer.InitLedger(transactionContext)
 	require.NoError(t, err)
+	require.Contains(t, l.writes, compositeKey(t, "Fuelcell", "FuelCell1"))
+	require.Contains(t, l.writes, compositeKey(t, "Journey", "Journey1"))
 
-	chaincodeStub.GetStateReturns(nil, nil)
-	err = assetTransfer.DeleteAsset(transactionContext, "asset1")
-	require.EqualError(t, err, "the asset asset1 does not exist")
+	chaincodeStub.PutStateReturns(fmt.Errorf("failed inserting key"))
+	err = assetTransfer.InitLedger(transactionContext)
+	require.EqualError(t, err, "failed to put to world state. failed inserting key")
 
-	chaincodeStub.GetStateReturns(nil, fmt.Errorf("unable to retrieve asset"))
-	err = assetTransfer.DeleteAsset(transactionContext, "")
-	require.EqualError(t, err, "failed to read from world state: unable to retrieve asset")
+	transactionContext, _ = l.context("Org1MSP", fleetOperator)
+	err = assetTransfer.InitLedger(transactionContext)
+	require.Error(t, err)
 }
 
-func TestTransferAsset(t *testing.T) {
-	chaincodeStub := &mocks.ChaincodeStub{}
-	transactionContext := &mocks.TransactionContext{}
-	transactionContext.GetStubReturns(chaincodeStub)
+func TestGenerateBill(t *testing.T) {
+	tests := []struct {
+		name      string
+		pastBills []chaincode.Bill
+		billID    string
+		startDate string
+		endDate   string
+		amount    chaincode.Money
+		billed    []string
+		err       string
+	}{
+		{
+			name:      "charges the days held and the unbilled journeys of every placement",
+			billID:    "Bill1",
+			startDate: "20200101",
+			endDate:   "20200131",
+			amount:    "71.00", // 31 days, Journey1 at 20 + 5 and Journey3 at 10 + 5
+			billed:    []string{"Journey1", "Journey3"},
+		},
+		{
+			name:      "follows on from the previous bill",
+			pastBills: []chaincode.Bill{{Bill_ID: "Bill0", Date_from: 20200101, Date_to: 20200131}},
+			billID:    "Bill1",
+			startDate: "20200201",
+			endDate:   "20200229",
+			amount:    "31.00", // 29 days and Journey4 at 2
+			billed:    []string{"Journey4"},
+		},
+		{
+			name:      "a bill ending the day before does not overlap",
+			pastBills: []chaincode.Bill{{Bill_ID: "Bill0", Date_from: 20191201, Date_to: 20191231}},
+			billID:    "Bill1",
+			startDate: "2020-01-01",
+			endDate:   "2020-01-31",
+			amount:    "71.00",
+			billed:    []string{"Journey1", "Journey3"},
+		},
+		{
+			name:      "a bill covering part of the period is rejected",
+			pastBills: []chaincode.Bill{{Bill_ID: "Bill0", Date_from: 20200120, Date_to: 20200210}},
+			billID:    "Bill1",
+			startDate: "20200101",
+			endDate:   "20200131",
+			err:       "this bill would overlap the time frame 20200120-20200210 covered by bill Bill0",
+		},
+		{
+			name:      "a bill sharing a single day is rejected",
+			pastBills: []chaincode.Bill{{Bill_ID: "Bill0", Date_from: 20200131, Date_to: 20200131}},
+			billID:    "Bill1",
+			startDate: "20200101",
+			endDate:   "20200131",
+			err:       "this bill would overlap the time frame 20200131-20200131 covered by bill Bill0",
+		},
+		{
+			name:      "a bill for another fuel cell is no overlap",
+			pastBills: []chaincode.Bill{{Bill_ID: "Bill0", Fuelcell_ID: "FuelCell2", Date_from: 20200101, Date_to: 20200131}},
+			billID:    "Bill1",
+			startDate: "20200101",
+			endDate:   "20200131",
+			amount:    "71.00",
+			billed:    []string{"Journey1", "Journey3"},
+		},
+		{
+			name:      "a void bill is no overlap",
+			pastBills: []chaincode.Bill{{Bill_ID: "Bill0", Date_from: 20200101, Date_to: 20200131, Status: chaincode.BillVoid}},
+			billID:    "Bill1",
+			startDate: "20200101",
+			endDate:   "20200131",
+			amount:    "71.00",
+			billed:    []string{"Journey1", "Journey3"},
+		},
+		{
+			name:      "an existing bill ID is rejected",
+			pastBills: []chaincode.Bill{{Bill_ID: "Bill1", Date_from: 20191201, Date_to: 20191231}},
+			billID:    "Bill1",
+			startDate: "20200101",
+			endDate:   "20200131",
+			err:       "the bill Bill1 already exists",
+		},
+		{
+			name:      "a period before the fuel cell was received is rejected",
+			billID:    "Bill1",
+			startDate: "20191201",
+			endDate:   "20191231",
+			err:       "fuelcell FuelCell1 didn't exist in this time frame",
+		},
+		{
+			name:      "a period ending before it starts is rejected",
+			billID:    "Bill1",
+			startDate: "20200131",
+			endDate:   "20200101",
+			err:       "the end date 20200101 is before the start date 20200131",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assets, rates := billingAssets(t)
+			for _, bill := range test.pastBills {
+				bill.AssetType = "Bill"
+				bill.Supplier_ID = "Supplier1"
+				if bill.Fuelcell_ID == "" {
+					bill.Fuelcell_ID = "FuelCell1"
+				}
+				bill.Currency = "GBP"
+				bill.Amount = "1.00"
+				if bill.Status == "" {
+					bill.Status = chaincode.BillIssued
+				}
+				assets[bill.Bill_ID] = bill
+			}
+			l := newLedger(t, assets, rates)
+			transactionContext, _ := l.context("Org1MSP", billingAdmin)
+
+			err := (&chaincode.SmartContract{}).GenerateBill(transactionContext, test.billID, "FuelCell1", test.startDate, test.endDate)
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+				require.Empty(t, l.writes)
+				return
+			}
+			require.NoError(t, err)
+
+			var bill chaincode.Bill
+			require.NoError(t, json.Unmarshal(l.writes[compositeKey(t, "Bill", test.billID)], &bill))
+			require.Equal(t, test.amount, bill.Amount)
+			require.Equal(t, chaincode.BillIssued, bill.Status)
+			require.Equal(t, 20200301, bill.Status_date)
+
+			// every charged journey is marked billed and no other journey is written
+			var billed []string
+			for key, value := range l.writes {
+				if !strings.HasPrefix(key, compositeKey(t, "Journey")) {
+					continue
+				}
+				var journey chaincode.JourneyData
+				require.NoError(t, json.Unmarshal(value, &journey))
+				require.True(t, journey.Billed, "%s written but not marked billed", journey.Journey_ID)
+				billed = append(billed, journey.Journey_ID)
+			}
+			sort.Strings(billed)
+			require.Equal(t, test.billed, billed)
+			journeyIDs := append([]string{}, bill.Journey_IDs...)
+			sort.Strings(journeyIDs)
+			require.Equal(t, test.billed, journeyIDs)
+		})
+	}
+}
 
-	asset := &chaincode.Asset{ID: "asset1"}
-	bytes, err := json.Marshal(asset)
+func TestGetBillBreakdown(t *testing.T) {
+	tariff1, rates1 := tariff(t, "FuelCell1", 20200101, chaincode.TariffRates{Base_rate: "1", Distance_rate: "0.2", Energy_rate: "1"})
+	l := newLedger(t, map[string]interface{}{
+		"Supplier1":       chaincode.Supplier{AssetType: "Supplier", Supplier_ID: "Supplier1", Supplier_name: "Hydrogen1", MSP_ID: "Org2MSP"},
+		"FuelCell1":       chaincode.FuelcellData{AssetType: "Fuelcell", Fuelcell_ID: "FuelCell1", Supplier_ID: "Supplier1", Currency: "GBP", Date_Received: 20200101},
+		"Component1":      chaincode.CarComponent{AssetType: "Car_Component", Car_Component_ID: "Component1", Car_ID: "Car1", Fuelcell_ID: "FuelCell1", Date_added: 20200101},
+		"Journey1":        chaincode.JourneyData{AssetType: "Journey", Journey_ID: "Journey1", Car_ID: "Car1", Car_Component_ID: "Component1", Distance: 100, H2_used: 10, Efficiency: 0.5, Journey_date: 20200105},
+		tariff1.Tariff_ID: tariff1,
+	}, map[string]chaincode.TariffRates{tariff1.Tariff_ID: rates1})
+	contract := &chaincode.SmartContract{}
+	transactionContext, _ := l.context("Org1MSP", billingAdmin)
+	require.NoError(t, contract.GenerateBill(transactionContext, "Bill1", "FuelCell1", "20200101", "20200131"))
+	require.NotContains(t, string(l.writes[compositeKey(t, "Bill", "Bill1")]), "Line_items\"", "line items reveal the rates so are not public")
+	require.Contains(t, string(l.privateWrites[compositeKey(t, "Bill_Lines", "Bill1")]), `"Energy_cost":""`, "a line of base days has no energy cost")
+	for key, value := range l.writes {
+		l.state[key] = value
+	}
+	for key, value := range l.privateWrites {
+		l.private[key] = value
+	}
+
+	// the bill written by GenerateBill reads back with every line item intact
+	breakdown, err := contract.GetBillBreakdown(transactionContext, "Bill1")
 	require.NoError(t, err)
-
-	chaincodeStub.GetStateReturns(bytes, nil)
-	assetTransfer := chaincode.SmartContract{}
-	_, err = assetTransfer.TransferAsset(transactionContext, "", "")
+	require.Equal(t, chaincode.Money("56.00"), breakdown.Amount)
+	require.Len(t, breakdown.Line_items, 2)
+	baseDays, journey := breakdown.Line_items[0], breakdown.Line_items[1]
+	require.Equal(t, chaincode.LineBaseDays, baseDays.Item_type)
+	require.Equal(t, 31, baseDays.Days)
+	require.Equal(t, chaincode.Money("31.00"), baseDays.Amount)
+	require.Empty(t, baseDays.Energy_cost)
+	require.Equal(t, chaincode.LineJourney, journey.Item_type)
+	require.Equal(t, "Journey1", journey.Journey_ID)
+	require.Equal(t, chaincode.Money("20.00"), journey.Distance_cost)
+	require.Equal(t, chaincode.Money("5.00"), journey.Energy_cost)
+	require.Empty(t, journey.Base_cost)
+
+	bill, err := contract.ReadBill(transactionContext, "Bill1")
 	require.NoError(t, err)
+	require.Equal(t, []string{"Journey1"}, bill.Journey_IDs)
 
-	chaincodeStub.GetStateReturns(nil, fmt.Errorf("unable to retrieve asset"))
-	_, err = assetTransfer.TransferAsset(transactionContext, "", "")
-	require.EqualError(t, err, "failed to read from world state: unable to retrieve asset")
+	// line items altered in the collection no longer match the hash on the bill
+	l.private[compositeKey(t, "Bill_Lines", "Bill1")] = []byte(`{"AssetType":"Bill_Lines","Bill_ID":"Bill1","Currency":"GBP","Line_items":[]}`)
+	_, err = contract.GetBillBreakdown(transactionContext, "Bill1")
+	require.EqualError(t, err, "the line items of bill Bill1 do not match the hash on the ledger")
 }
 
-func TestGetAllAssets(t *testing.T) {
-	asset := &chaincode.Asset{ID: "asset1"}
-	bytes, err := json.Marshal(asset)
-	require.NoError(t, err)
-
-	iterator := &mocks.StateQueryIterator{}
-	iterator.HasNextReturnsOnCall(0, true)
-	iterator.HasNextReturnsOnCall(1, false)
-	iterator.NextReturns(&queryresult.KV{Value: bytes}, nil)
+func TestGetAllCarCompForFuelCellBetweenDates(t *testing.T) {
+	tests := []struct {
+		name       string
+		startDate  string
+		endDate    string
+		components []string
+	}{
+		{"before the fuel cell was fitted", "20191201", "20191231", nil},
+		{"within the first placement", "20200101", "20200110", []string{"Component1"}},
+		{"the day before the move", "20200114", "20200114", []string{"Component1"}},
+		{"the day it moved belongs to the new placement only", "20200115", "20200115", []string{"Component2"}},
+		{"spanning the move", "20200110", "20200120", []string{"Component1", "Component2"}},
+		{"after the move", "20200116", "20200131", []string{"Component2"}},
+		{"long after the move while still fitted", "20210101", "20210131", []string{"Component2"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assets, rates := billingAssets(t)
+			transactionContext, _ := newLedger(t, assets, rates).context("Org1MSP", fleetOperator)
+
+			components, err := (&chaincode.SmartContract{}).GetAllCarCompForFuelCellBetweenDates(transactionContext, "FuelCell1", test.startDate, test.endDate)
+			require.NoError(t, err)
+			var ids []string
+			for _, component := range components {
+				ids = append(ids, component.Car_Component_ID)
+			}
+			require.Equal(t, test.components, ids)
+		})
+	}
+}
 
-	chaincodeStub := &mocks.ChaincodeStub{}
-	transactionContext := &mocks.TransactionContext{}
-	transactionContext.GetStubReturns(chaincodeStub)
+func TestGetAllJourneysbetweendatesforCarComponent(t *testing.T) {
+	tests := []struct {
+		name      string
+		component string
+		startDate string
+		endDate   string
+		journeys  []string
+	}{
+		{"whole placement", "Component1", "20200101", "20200115", []string{"Journey1", "Journey2"}},
+		{"bounds are inclusive", "Component1", "20200105", "20200110", []string{"Journey1", "Journey2"}},
+		{"billed journeys are still listed", "Component1", "20200110", "20200110", []string{"Journey2"}},
+		{"only the component's journeys", "Component2", "20200101", "20200131", []string{"Journey3"}},
+		{"no journeys in the period", "Component2", "20200121", "20200204", nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assets, rates := billingAssets(t)
+			transactionContext, _ := newLedger(t, assets, rates).context("Org1MSP", fleetOperator)
+
+			journeys, err := (&chaincode.SmartContract{}).GetAllJourneysbetweendatesforCarComponent(transactionContext, test.component, test.startDate, test.endDate)
+			require.NoError(t, err)
+			var ids []string
+			for _, journey := range journeys {
+				ids = append(ids, journey.Journey_ID)
+			}
+			require.Equal(t, test.journeys, ids)
+		})
+	}
+}
 
-	chaincodeStub.GetStateByRangeReturns(iterator, nil)
-	assetTransfer := &chaincode.SmartContract{}
-	assets, err := assetTransfer.GetAllAssets(transactionContext)
-	require.NoError(t, err)
-	require.Equal(t, []*chaincode.Asset{asset}, assets)
-
-	iterator.HasNextReturns(true)
-	iterator.NextReturns(nil, fmt.Errorf("failed retrieving next item"))
-	assets, err = assetTransfer.GetAllAssets(transactionContext)
-	require.EqualError(t, err, "failed retrieving next item")
-	require.Nil(t, assets)
-
-	chaincodeStub.GetStateByRangeReturns(nil, fmt.Errorf("failed retrieving all assets"))
-	assets, err = assetTransfer.GetAllAssets(transactionContext)
-	require.EqualError(t, err, "failed retrieving all assets")
-	require.Nil(t, assets)
+func TestCreateJourney(t *testing.T) {
+	tests := []struct {
+		name      string
+		car       string
+		component string
+		odoStart  string
+		date      string
+		err       string
+	}{
+		{"on the day the component was fitted", "Car1", "Component1", "0", "20200101", ""},
+		{"while the component was fitted", "Car1", "Component1", "150", "20200114", ""},
+		{"before the component was fitted", "Car1", "Component1", "0", "20191231", "the Car_Component Component1 was not fitted to Car Car1 on 20191231"},
+		{"on the day the component was removed", "Car1", "Component1", "150", "20200115", "the Car_Component Component1 was not fitted to Car Car1 on 20200115"},
+		{"with a component of another car", "Car1", "Component2", "150", "20200120", "the Car_Component Component2 belongs to Car Car2 not Car Car1"},
+		{"while an open ended component is fitted", "Car2", "Component2", "60", "20200301", ""},
+		{"with the odometer behind an earlier journey", "Car1", "Component1", "120", "20200112", "the Odo_start 120 is below the end of Journey Journey2 at 150"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assets, rates := billingAssets(t)
+			l := newLedger(t, assets, rates)
+			transactionContext, _ := l.context("Org1MSP", fleetOperator)
+
+			err := (&chaincode.SmartContract{}).CreateJourney(transactionContext, "Journey9", test.car, test.component, test.odoStart, "10", "1", 0.5, "Supplier1", test.date)
+			if test.err != "" {
+				require.EqualError(t, err, test.err)
+				require.Empty(t, l.writes)
+				return
+			}
+			require.NoError(t, err)
+			var journey chaincode.JourneyData
+			require.NoError(t, json.Unmarshal(l.writes[compositeKey(t, "Journey", "Journey9")], &journey))
+			require.False(t, journey.Billed)
+			require.Equal(t, test.component, journey.Car_Component_ID)
+		})
+	}
 }
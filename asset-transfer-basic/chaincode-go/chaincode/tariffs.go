@@ -0,0 +1,355 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Effective dated tariffs. Each Tariff sets a fuel cell's rates from its Effective_from date until the
+// next Tariff takes effect, so a renegotiated rate never reprices days or journeys before it was agreed.
+// The first tariff of a fuel cell takes effect on the day it was received.
+//
+// Rates are commercially sensitive, so they are held in the tariffCollection private data collection
+// shared by the fleet operator and suppliers. The world state only holds the Tariff with a hash of its
+// rates, and rates are passed in the transient map rather than as arguments so they never appear in a
+// block. Fuel cells recorded before rates were private have their rates on the world state record;
+// these are ignored and a tariff must be set from Date_Received with SetFuelcellTariff before billing.
+// MigrateFuelcellRates removes them from the current record, though earlier blocks still hold them.
+
+// tariffCollection is the private data collection holding the rates of every tariff
+const tariffCollection = "fleetSupplierTariffs"
+
+// tariffTransientKey is the transient map entry carrying a TariffRates as JSON, eg
+// {"Base_rate":"1","Distance_rate":"0.2","Energy_rate":"1","Salt":"9f1c2b7e44d0a3e5"}
+const tariffTransientKey = "tariff_rates"
+
+// minSaltLength is the shortest salt accepted with a set of rates
+const minSaltLength = 16
+
+// Tariff is the public record of a set of rates charged for a fuel cell from Effective_from onwards
+type Tariff struct {
+	AssetType      string `json:"AssetType"`
+	Tariff_ID      string `json:"Tariff_ID"`
+	Fuelcell_ID    string `json:"Fuelcell_ID"`
+	Effective_from int    `json:"Effective_from"`
+	Rates_hash     string `json:"Rates_hash"` // hex SHA-256 of the TariffRates JSON held in the tariff collection
+	// MSP of the client whose transaction last wrote the asset, see history.go
+	Last_submitted_by string `json:"Last_submitted_by,omitempty" metadata:",optional"`
+}
+
+// TariffRates are the rates of a tariff, held in the tariff collection under the Tariff_ID
+type TariffRates struct {
+	AssetType     string `json:"AssetType"`
+	Tariff_ID     string `json:"Tariff_ID"`
+	Base_rate     Rate   `json:"Base_rate"`     // charge per day the fuel cell is held
+	Distance_rate Rate   `json:"Distance_rate"` // charge per distance unit travelled
+	Energy_rate   Rate   `json:"Energy_rate"`   // charge per gramme of H2 used, scaled by efficiency
+	Salt          string `json:"Salt"`          // random text chosen by the supplier so the hash cannot be matched by guessing rates
+}
+
+// tariffPeriod is a tariff and its rates together with the days it was in force
+type tariffPeriod struct {
+	tariff  *Tariff
+	rates   *TariffRates
+	inForce period
+}
+
+// SetFuelcellTariff records new rates for a fuel cell taking effect on effectiveFrom. The rates are read
+// from the tariff_rates entry of the transient map. Rates can only be set from the transaction date on,
+// never for days that have already been billed, and a tariff once set is never replaced.
+func (s *SmartContract) SetFuelcellTariff(ctx contractapi.TransactionContextInterface, fuelcellID string, effectiveFrom string) error {
+	err := s.assertFuelcellSupplier(ctx, fuelcellID)
+	if err != nil {
+		return err
+	}
+	fuelcell, err := s.ReadFuelcell(ctx, fuelcellID)
+	if err != nil {
+		return err
+	}
+	intEffectiveFrom, err := parseLedgerDate(effectiveFrom)
+	if err != nil {
+		return err
+	}
+	if intEffectiveFrom < fuelcell.Date_Received {
+		return fmt.Errorf("the tariff cannot take effect before the Fuelcell %s was received on %d", fuelcellID, fuelcell.Date_Received)
+	}
+	today, err := txDate(ctx)
+	if err != nil {
+		return err
+	}
+	if intEffectiveFrom < today {
+		return fmt.Errorf("the tariff cannot take effect on %d, before the transaction date %d", intEffectiveFrom, today)
+	}
+	exists, err := assetExists(ctx, "Tariff", tariffID(fuelcellID, intEffectiveFrom))
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("the Tariff %s already exists", tariffID(fuelcellID, intEffectiveFrom))
+	}
+	bills, err := billsMatching(ctx, selector{"AssetType": "Bill", "Fuelcell_ID": fuelcellID})
+	if err != nil {
+		return err
+	}
+	for _, bill := range bills {
+		if bill.Fuelcell_ID == fuelcellID && bill.Status != BillVoid && intEffectiveFrom <= bill.Date_to {
+			return fmt.Errorf("the Fuelcell %s has been billed up to %d by bill %s", fuelcellID, bill.Date_to, bill.Bill_ID)
+		}
+	}
+	rates, err := ratesFromTransient(ctx)
+	if err != nil {
+		return err
+	}
+	return putTariff(ctx, fuelcellID, intEffectiveFrom, rates)
+}
+
+// GetFuelcellTariffs returns the public record of every tariff a fuel cell has been charged at, oldest first
+func (s *SmartContract) GetFuelcellTariffs(ctx contractapi.TransactionContextInterface, fuelcellID string) ([]*Tariff, error) {
+	_, err := s.ReadFuelcell(ctx, fuelcellID)
+	if err != nil {
+		return nil, err
+	}
+	return fuelcellTariffs(ctx, fuelcellID)
+}
+
+// legacyRateFields are the rates of a fuel cell recorded before rates were private
+var legacyRateFields = []string{"Base_rate", "Distance_rate", "energy_rate"}
+
+// MigrateFuelcellRates rewrites every fuel cell still holding public rates without them, converting a
+// legacy currency such as "Pounds" to its ISO 4217 code, and returns the number rewritten. It only needs
+// to be run once, after every page of MigrateAssetKeys, and is safe to repeat.
+func (s *SmartContract) MigrateFuelcellRates(ctx contractapi.TransactionContextInterface) (int, error) {
+	err := assertBillingAdmin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	// every fuel cell is keyed under the Fuelcell object type, see keys.go
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey("Fuelcell", []string{})
+	if err != nil {
+		return 0, err
+	}
+	defer resultsIterator.Close()
+
+	migrated := 0
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return 0, err
+		}
+		var fields map[string]json.RawMessage
+		err = json.Unmarshal(queryResponse.Value, &fields)
+		if err != nil {
+			return 0, err
+		}
+		legacy := false
+		for _, field := range legacyRateFields {
+			if _, ok := fields[field]; ok {
+				legacy = true
+			}
+		}
+		if !legacy {
+			continue
+		}
+		var fuelcell FuelcellData
+		err = json.Unmarshal(queryResponse.Value, &fuelcell)
+		if err != nil {
+			return 0, err
+		}
+		if code, ok := currencyAliases[fuelcell.Currency]; ok {
+			fuelcell.Currency = code // written before currencies were ISO 4217 codes
+		}
+		err = putAsset(ctx, fuelcell) // only the fields of FuelcellData are written back
+		if err != nil {
+			return 0, fmt.Errorf("failed to rewrite Fuelcell %s: %v", fuelcell.Fuelcell_ID, err)
+		}
+		migrated++
+	}
+	return migrated, nil
+}
+
+// ReadTariffRates returns the rates of a tariff from the tariff collection. They may be read by anyone who
+// may read the bills of the fuel cell's supplier, from a peer of an organisation in the collection.
+func (s *SmartContract) ReadTariffRates(ctx contractapi.TransactionContextInterface, tariffID string) (*TariffRates, error) {
+	var tariff Tariff
+	err := readAsset(ctx, tariffID, "Tariff", &tariff)
+	if err != nil {
+		return nil, err
+	}
+	fuelcell, err := s.ReadFuelcell(ctx, tariff.Fuelcell_ID)
+	if err != nil {
+		return nil, err
+	}
+	err = s.assertBillReader(ctx, fuelcell.Supplier_ID)
+	if err != nil {
+		return nil, err
+	}
+	return readTariffRates(ctx, &tariff)
+}
+
+// tariffID is the ID of the tariff of a fuel cell taking effect on effectiveFrom
+func tariffID(fuelcellID string, effectiveFrom int) string {
+	return fmt.Sprintf("%s_tariff_%d", fuelcellID, effectiveFrom)
+}
+
+// putTariff writes a fuel cell's rates to the tariff collection and the public record of the tariff,
+// holding their hash, to the world state
+func putTariff(ctx contractapi.TransactionContextInterface, fuelcellID string, effectiveFrom int, rates *TariffRates) error {
+	tariff := Tariff{
+		AssetType:      "Tariff",
+		Tariff_ID:      tariffID(fuelcellID, effectiveFrom),
+		Fuelcell_ID:    fuelcellID,
+		Effective_from: effectiveFrom,
+	}
+	rates.AssetType = "Tariff_Rates"
+	rates.Tariff_ID = tariff.Tariff_ID
+	err := rates.validate()
+	if err != nil {
+		return err
+	}
+	ratesJSON, err := json.Marshal(rates)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().PutPrivateData(tariffCollection, tariff.Tariff_ID, ratesJSON)
+	if err != nil {
+		return fmt.Errorf("failed to put rates into private data collection: %v", err)
+	}
+	hash := sha256.Sum256(ratesJSON)
+	tariff.Rates_hash = hex.EncodeToString(hash[:])
+	return putAsset(ctx, tariff)
+}
+
+// readTariffRates reads the rates of a tariff from the tariff collection, checking they match the hash
+// on the world state
+func readTariffRates(ctx contractapi.TransactionContextInterface, tariff *Tariff) (*TariffRates, error) {
+	ratesJSON, err := ctx.GetStub().GetPrivateData(tariffCollection, tariff.Tariff_ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rates from private data collection: %v", err)
+	}
+	if ratesJSON == nil {
+		return nil, fmt.Errorf("the rates of Tariff %s are not held by this peer", tariff.Tariff_ID)
+	}
+	hash := sha256.Sum256(ratesJSON)
+	if hex.EncodeToString(hash[:]) != tariff.Rates_hash {
+		return nil, fmt.Errorf("the rates of Tariff %s do not match the hash on the ledger", tariff.Tariff_ID)
+	}
+	var rates TariffRates
+	err = json.Unmarshal(ratesJSON, &rates)
+	if err != nil {
+		return nil, err
+	}
+	return &rates, nil
+}
+
+// ratesFromTransient reads and validates the rates passed in the transient map
+func ratesFromTransient(ctx contractapi.TransactionContextInterface) (*TariffRates, error) {
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("error getting transient: %v", err)
+	}
+	// Rates are private, therefore they get passed in the transient field instead of function args
+	transientRatesJSON, ok := transientMap[tariffTransientKey]
+	if !ok {
+		return nil, fmt.Errorf("%s not found in the transient map input", tariffTransientKey)
+	}
+	var input TariffRates
+	err = json.Unmarshal(transientRatesJSON, &input)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %v", err)
+	}
+	rates, err := parseRates(string(input.Base_rate), string(input.Distance_rate), string(input.Energy_rate))
+	if err != nil {
+		return nil, err
+	}
+	rates.Salt = input.Salt
+	return rates, nil
+}
+
+// fuelcellTariffs returns the public records of a fuel cell's tariffs in date order
+func fuelcellTariffs(ctx contractapi.TransactionContextInterface, fuelcellID string) ([]*Tariff, error) {
+	resultsIterator, err := ctx.GetStub().GetQueryResult(selector{"AssetType": "Tariff", "Fuelcell_ID": fuelcellID}.query())
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+	tariffs := []*Tariff{}
+	for resultsIterator.HasNext() {
+		queryResult, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var tariff Tariff
+		err = json.Unmarshal(queryResult.Value, &tariff)
+		if err != nil {
+			return nil, err
+		}
+		tariffs = append(tariffs, &tariff)
+	}
+	sort.SliceStable(tariffs, func(i, j int) bool {
+		return tariffs[i].Effective_from < tariffs[j].Effective_from
+	})
+	return tariffs, nil
+}
+
+// tariffSchedule returns the tariffs of a fuel cell in date order with their rates and the days each
+// was in force
+func tariffSchedule(ctx contractapi.TransactionContextInterface, fuelcell *FuelcellData) ([]tariffPeriod, error) {
+	tariffs, err := fuelcellTariffs(ctx, fuelcell.Fuelcell_ID)
+	if err != nil {
+		return nil, err
+	}
+	schedule := make([]tariffPeriod, len(tariffs))
+	for i, tariff := range tariffs {
+		rates, err := readTariffRates(ctx, tariff)
+		if err != nil {
+			return nil, err
+		}
+		from, err := dateFromLedger(tariff.Effective_from)
+		if err != nil {
+			return nil, err
+		}
+		schedule[i] = tariffPeriod{tariff: tariff, rates: rates, inForce: period{from: from, to: openEnded}}
+		if i > 0 {
+			schedule[i-1].inForce.to = from.AddDate(0, 0, -1)
+		}
+	}
+	return schedule, nil
+}
+
+// tariffOn returns the tariff in force on the given day
+func tariffOn(schedule []tariffPeriod, date int) (*tariffPeriod, error) {
+	day, err := dateFromLedger(date)
+	if err != nil {
+		return nil, err
+	}
+	for i := range schedule {
+		if schedule[i].inForce.contains(day) {
+			return &schedule[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no tariff was in force on %d", date)
+}
+
+// parseRates validates a set of rates, returning them in canonical form
+func parseRates(baseRate string, distanceRate string, energyRate string) (*TariffRates, error) {
+	var rates TariffRates
+	var err error
+	rates.Base_rate, err = ParseRate(baseRate)
+	if err != nil {
+		return nil, err
+	}
+	rates.Distance_rate, err = ParseRate(distanceRate)
+	if err != nil {
+		return nil, err
+	}
+	rates.Energy_rate, err = ParseRate(energyRate)
+	if err != nil {
+		return nil, err
+	}
+	return &rates, nil
+}
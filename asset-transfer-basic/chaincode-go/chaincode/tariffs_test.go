@@ -0,0 +1,102 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/chaincode/mocks"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestSetFuelcellTariffKeepsRatesPrivate(t *testing.T) {
+	assets := map[string]interface{}{
+		"Supplier1": Supplier{AssetType: "Supplier", Supplier_ID: "Supplier1", Supplier_name: "Hydrogen1", MSP_ID: "Org2MSP"},
+		"FuelCell1": FuelcellData{AssetType: "Fuelcell", Fuelcell_ID: "FuelCell1", Supplier_ID: "Supplier1", Currency: "GBP", Date_Received: 20200122},
+	}
+	ctx, err := clientWith("Org2MSP", map[string]string{roleAttribute: RoleSupplier, supplierAttribute: "Supplier1"}, assets)
+	require.NoError(t, err)
+	chaincodeStub := ctx.GetStub().(*mocks.ChaincodeStub)
+	chaincodeStub.GetQueryResultReturns(&mocks.StateQueryIterator{}, nil)
+
+	err = (&SmartContract{}).SetFuelcellTariff(ctx, "FuelCell1", "20200301")
+	require.EqualError(t, err, "tariff_rates not found in the transient map input")
+
+	chaincodeStub.GetTransientReturns(map[string][]byte{
+		tariffTransientKey: []byte(`{"Base_rate":"1.50","Distance_rate":"0.2","Energy_rate":"1","Salt":"3b9e0c51d7a24f86"}`),
+	}, nil)
+	err = (&SmartContract{}).SetFuelcellTariff(ctx, "FuelCell1", "20200301")
+	require.NoError(t, err)
+
+	require.Equal(t, 1, chaincodeStub.PutPrivateDataCallCount())
+	collection, key, ratesJSON := chaincodeStub.PutPrivateDataArgsForCall(0)
+	require.Equal(t, tariffCollection, collection)
+	require.Equal(t, "FuelCell1_tariff_20200301", key)
+	var rates TariffRates
+	require.NoError(t, json.Unmarshal(ratesJSON, &rates))
+	require.Equal(t, Rate("1.5"), rates.Base_rate)
+
+	require.Equal(t, 1, chaincodeStub.PutStateCallCount())
+	key, tariffJSON := chaincodeStub.PutStateArgsForCall(0)
+	require.NotContains(t, string(tariffJSON), "1.5")
+	require.Equal(t, "\x00Tariff\x00FuelCell1_tariff_20200301\x00", key)
+	var tariff Tariff
+	require.NoError(t, json.Unmarshal(tariffJSON, &tariff))
+	hash := sha256.Sum256(ratesJSON)
+	require.Equal(t, hex.EncodeToString(hash[:]), tariff.Rates_hash)
+}
+
+func TestSetFuelcellTariffRejectsPastAndExistingTariffs(t *testing.T) {
+	assets := map[string]interface{}{
+		"Supplier1": Supplier{AssetType: "Supplier", Supplier_ID: "Supplier1", Supplier_name: "Hydrogen1", MSP_ID: "Org2MSP"},
+		"FuelCell1": FuelcellData{AssetType: "Fuelcell", Fuelcell_ID: "FuelCell1", Supplier_ID: "Supplier1", Currency: "GBP", Date_Received: 20200122},
+		"Tariff1":   Tariff{AssetType: "Tariff", Tariff_ID: "FuelCell1_tariff_20200401", Fuelcell_ID: "FuelCell1", Effective_from: 20200401},
+	}
+	ctx, err := clientWith("Org2MSP", map[string]string{roleAttribute: RoleSupplier, supplierAttribute: "Supplier1"}, assets)
+	require.NoError(t, err)
+	chaincodeStub := ctx.GetStub().(*mocks.ChaincodeStub)
+	chaincodeStub.GetQueryResultReturns(&mocks.StateQueryIterator{}, nil)
+	chaincodeStub.GetTransientReturns(map[string][]byte{
+		tariffTransientKey: []byte(`{"Base_rate":"1.50","Distance_rate":"0.2","Energy_rate":"1","Salt":"3b9e0c51d7a24f86"}`),
+	}, nil)
+	chaincodeStub.GetTxTimestampReturns(timestamppb.New(time.Date(2020, time.March, 2, 9, 0, 0, 0, time.UTC)), nil)
+
+	err = (&SmartContract{}).SetFuelcellTariff(ctx, "FuelCell1", "20200301")
+	require.EqualError(t, err, "the tariff cannot take effect on 20200301, before the transaction date 20200302")
+	err = (&SmartContract{}).SetFuelcellTariff(ctx, "FuelCell1", "20200401")
+	require.EqualError(t, err, "the Tariff FuelCell1_tariff_20200401 already exists")
+	require.Zero(t, chaincodeStub.PutPrivateDataCallCount())
+	require.Zero(t, chaincodeStub.PutStateCallCount())
+
+	err = (&SmartContract{}).SetFuelcellTariff(ctx, "FuelCell1", "20200302")
+	require.NoError(t, err)
+	collection, key, _ := chaincodeStub.PutPrivateDataArgsForCall(0)
+	require.Equal(t, tariffCollection, collection)
+	require.Equal(t, "FuelCell1_tariff_20200302", key)
+}
+
+func TestReadTariffRatesChecksHash(t *testing.T) {
+	ratesJSON := []byte(`{"AssetType":"Tariff_Rates","Tariff_ID":"FuelCell1_tariff_20200122","Base_rate":"1","Distance_rate":"0.2","Energy_rate":"1","Salt":"3b9e0c51d7a24f86"}`)
+	hash := sha256.Sum256(ratesJSON)
+	tariff := &Tariff{AssetType: "Tariff", Tariff_ID: "FuelCell1_tariff_20200122", Fuelcell_ID: "FuelCell1", Effective_from: 20200122, Rates_hash: hex.EncodeToString(hash[:])}
+
+	chaincodeStub := &mocks.ChaincodeStub{}
+	transactionContext := &mocks.TransactionContext{}
+	transactionContext.GetStubReturns(chaincodeStub)
+
+	chaincodeStub.GetPrivateDataReturns(ratesJSON, nil)
+	rates, err := readTariffRates(transactionContext, tariff)
+	require.NoError(t, err)
+	require.Equal(t, Rate("0.2"), rates.Distance_rate)
+
+	chaincodeStub.GetPrivateDataReturns([]byte(`{"AssetType":"Tariff_Rates","Tariff_ID":"FuelCell1_tariff_20200122","Base_rate":"0.1","Distance_rate":"0.2","Energy_rate":"1","Salt":"3b9e0c51d7a24f86"}`), nil)
+	_, err = readTariffRates(transactionContext, tariff)
+	require.EqualError(t, err, "the rates of Tariff FuelCell1_tariff_20200122 do not match the hash on the ledger")
+
+	chaincodeStub.GetPrivateDataReturns(nil, nil)
+	_, err = readTariffRates(transactionContext, tariff)
+	require.EqualError(t, err, "the rates of Tariff FuelCell1_tariff_20200122 are not held by this peer")
+}
@@ -0,0 +1,235 @@
+package chaincode
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Validation of assets on write. Every asset is checked by putAsset before it reaches the world state,
+// so a record that breaks a rule is refused whichever transaction wrote it. All the problems with a
+// record are reported together, each against the field it concerns.
+
+// FieldError is one problem with one field of an asset
+type FieldError struct {
+	Field   string `json:"Field"`
+	Message string `json:"Message"`
+}
+
+// ValidationError lists everything wrong with an asset. Its message ends with the field errors as a
+// JSON array so a client can show each one against the field it came from.
+type ValidationError struct {
+	AssetType string       `json:"AssetType"`
+	ID        string       `json:"ID"`
+	Fields    []FieldError `json:"Fields"`
+}
+
+func (e *ValidationError) Error() string {
+	fieldsJSON, err := json.Marshal(e.Fields)
+	if err != nil {
+		return fmt.Sprintf("invalid %s %s", e.AssetType, e.ID)
+	}
+	return fmt.Sprintf("invalid %s %s: %s", e.AssetType, e.ID, fieldsJSON)
+}
+
+// validatedAsset is an asset which can check its own fields before it is written
+type validatedAsset interface {
+	validate() error
+}
+
+// validation collects the field errors found in one asset
+type validation struct {
+	assetType string
+	id        string
+	fields    []FieldError
+}
+
+// newValidation starts checking an asset, which must have the expected AssetType and an ID
+func newValidation(assetType string, actualType string, idField string, id string) *validation {
+	v := &validation{assetType: assetType, id: id}
+	if actualType != assetType {
+		v.fail("AssetType", "must be %s", assetType)
+	}
+	v.required(idField, id)
+	return v
+}
+
+// err returns the ValidationError for the asset, or nil if no problems were found
+func (v *validation) err() error {
+	if len(v.fields) == 0 {
+		return nil
+	}
+	return &ValidationError{AssetType: v.assetType, ID: v.id, Fields: v.fields}
+}
+
+func (v *validation) fail(field string, format string, args ...interface{}) {
+	v.fields = append(v.fields, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validation) required(field string, value string) {
+	if strings.TrimSpace(value) == "" {
+		v.fail(field, "is required")
+	}
+}
+
+// date checks a stored YYYYMMDD date; open allows 0 for a date which has not happened yet
+func (v *validation) date(field string, value int, open bool) {
+	if open && value == 0 {
+		return
+	}
+	_, err := dateFromLedger(value)
+	if err != nil {
+		v.fail(field, "must be a date in the form YYYYMMDD")
+	}
+}
+
+// dateOrder checks a period does not end before it starts, where an end of 0 means still open
+func (v *validation) dateOrder(field string, from int, to int) {
+	if to != 0 && to < from {
+		v.fail(field, "must not be before %d", from)
+	}
+}
+
+func (v *validation) nonNegative(field string, value int) {
+	if value < 0 {
+		v.fail(field, "must not be negative")
+	}
+}
+
+func (v *validation) rate(field string, value Rate) {
+	_, err := ParseRate(string(value))
+	if err != nil {
+		v.fail(field, "must be a non negative decimal such as 0.2")
+	}
+}
+
+// currency checks for an ISO 4217 code; the legacy aliases are accepted as arguments but never stored
+func (v *validation) currency(field string, value string) bool {
+	if _, ok := currencyPolicies[value]; !ok {
+		v.fail(field, "must be a supported ISO 4217 currency code such as GBP")
+		return false
+	}
+	return true
+}
+
+func (v *validation) money(field string, value Money, currency string) {
+	_, err := ParseMoney(string(value), currency)
+	if err != nil {
+		v.fail(field, "must be a decimal amount in %s", currency)
+	}
+}
+
+func (asset Car) validate() error {
+	v := newValidation("Car", asset.AssetType, "Car_ID", asset.Car_ID)
+	_, err := time.Parse(ledgerDateLayout, asset.Date_of_manufacture)
+	if err != nil {
+		v.fail("Date_of_manufacture", "must be a date in the form YYYYMMDD")
+	}
+	v.date("Date_retired", asset.Date_retired, true)
+	return v.err()
+}
+
+func (asset CarComponent) validate() error {
+	v := newValidation("Car_Component", asset.AssetType, "Car_Component_ID", asset.Car_Component_ID)
+	v.required("Car_ID", asset.Car_ID)
+	v.required("Fuelcell_ID", asset.Fuelcell_ID)
+	v.date("Date_added", asset.Date_added, false)
+	v.date("Date_removed", asset.Date_removed, true)
+	v.dateOrder("Date_removed", asset.Date_added, asset.Date_removed)
+	return v.err()
+}
+
+func (asset Supplier) validate() error {
+	v := newValidation("Supplier", asset.AssetType, "Supplier_ID", asset.Supplier_ID)
+	v.required("Supplier_name", asset.Supplier_name)
+	v.date("Date_retired", asset.Date_retired, true)
+	return v.err()
+}
+
+func (asset FuelcellData) validate() error {
+	v := newValidation("Fuelcell", asset.AssetType, "Fuelcell_ID", asset.Fuelcell_ID)
+	v.required("Supplier_ID", asset.Supplier_ID)
+	v.currency("Currency", asset.Currency)
+	v.date("Date_Received", asset.Date_Received, false)
+	v.date("Date_Returned", asset.Date_Returned, true)
+	v.dateOrder("Date_Returned", asset.Date_Received, asset.Date_Returned)
+	return v.err()
+}
+
+func (asset JourneyData) validate() error {
+	v := newValidation("Journey", asset.AssetType, "Journey_ID", asset.Journey_ID)
+	v.required("Car_ID", asset.Car_ID)
+	v.required("Car_Component_ID", asset.Car_Component_ID)
+	v.nonNegative("Odo_start", asset.Odo_start)
+	v.nonNegative("Distance", asset.Distance)
+	v.nonNegative("H2_used", asset.H2_used)
+	if asset.Efficiency < 0 || asset.Efficiency > 1 {
+		v.fail("Efficiency", "must be between 0 and 1")
+	}
+	v.date("Journey_date", asset.Journey_date, false)
+	return v.err()
+}
+
+func (asset Bill) validate() error {
+	v := newValidation("Bill", asset.AssetType, "Bill_ID", asset.Bill_ID)
+	v.required("Supplier_ID", asset.Supplier_ID)
+	v.required("Fuelcell_ID", asset.Fuelcell_ID)
+	v.date("Date_from", asset.Date_from, false)
+	v.date("Date_to", asset.Date_to, false)
+	v.dateOrder("Date_to", asset.Date_from, asset.Date_to)
+	if v.currency("Currency", asset.Currency) {
+		v.money("Amount", asset.Amount, asset.Currency)
+	}
+	switch asset.Status {
+	case BillIssued, BillAcknowledged, BillPaid, BillDisputed, BillCredited, BillVoid:
+	default:
+		v.fail("Status", "must be one of %s, %s, %s, %s, %s or %s", BillIssued, BillAcknowledged, BillPaid, BillDisputed, BillCredited, BillVoid)
+	}
+	v.date("Status_date", asset.Status_date, false)
+	return v.err()
+}
+
+func (asset CreditNote) validate() error {
+	v := newValidation("Credit_Note", asset.AssetType, "Credit_note_ID", asset.Credit_note_ID)
+	v.required("Bill_ID", asset.Bill_ID)
+	v.required("Supplier_ID", asset.Supplier_ID)
+	if v.currency("Currency", asset.Currency) {
+		v.money("Amount", asset.Amount, asset.Currency)
+	}
+	v.date("Date_issued", asset.Date_issued, false)
+	return v.err()
+}
+
+func (asset Tariff) validate() error {
+	v := newValidation("Tariff", asset.AssetType, "Tariff_ID", asset.Tariff_ID)
+	v.required("Fuelcell_ID", asset.Fuelcell_ID)
+	v.date("Effective_from", asset.Effective_from, false)
+	if hash, err := hex.DecodeString(asset.Rates_hash); err != nil || len(hash) != sha256.Size {
+		v.fail("Rates_hash", "must be a hex SHA-256 hash")
+	}
+	return v.err()
+}
+
+func (asset BillLines) validate() error {
+	v := newValidation("Bill_Lines", asset.AssetType, "Bill_ID", asset.Bill_ID)
+	if v.currency("Currency", asset.Currency) {
+		for i, item := range asset.Line_items {
+			v.money(fmt.Sprintf("Line_items[%d].Amount", i), item.Amount, asset.Currency)
+		}
+	}
+	return v.err()
+}
+
+func (asset TariffRates) validate() error {
+	v := newValidation("Tariff_Rates", asset.AssetType, "Tariff_ID", asset.Tariff_ID)
+	v.rate("Base_rate", asset.Base_rate)
+	v.rate("Distance_rate", asset.Distance_rate)
+	v.rate("Energy_rate", asset.Energy_rate)
+	if len(asset.Salt) < minSaltLength {
+		v.fail("Salt", "must be at least %d characters of random text", minSaltLength)
+	}
+	return v.err()
+}
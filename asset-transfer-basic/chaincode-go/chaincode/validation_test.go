@@ -0,0 +1,61 @@
+package chaincode
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	validFuelcell := FuelcellData{AssetType: "Fuelcell", Fuelcell_ID: "FuelCell1", Supplier_ID: "Supplier1", Currency: "GBP", Date_Received: 20200122}
+	validBill := Bill{AssetType: "Bill", Bill_ID: "Bill1", Supplier_ID: "Supplier1", Fuelcell_ID: "FuelCell1", Date_from: 20200101, Date_to: 20200131, Currency: "GBP", Amount: "31.50", Status: BillIssued, Status_date: 20200201}
+
+	tests := []struct {
+		name   string
+		asset  validatedAsset
+		fields []string
+	}{
+		{"valid car", Car{AssetType: "Car", Car_ID: "Car1", Date_of_manufacture: "20200123"}, nil},
+		{"car date not YYYYMMDD", Car{AssetType: "Car", Car_ID: "Car1", Date_of_manufacture: "2020-01-23"}, []string{"Date_of_manufacture"}},
+		{"car without ID or type", Car{Date_of_manufacture: "20200123"}, []string{"AssetType", "Car_ID"}},
+		{"component removed before added", CarComponent{AssetType: "Car_Component", Car_Component_ID: "Component1", Car_ID: "Car1", Fuelcell_ID: "FuelCell1", Date_added: 20200123, Date_removed: 20200122}, []string{"Date_removed"}},
+		{"component still fitted", CarComponent{AssetType: "Car_Component", Car_Component_ID: "Component1", Car_ID: "Car1", Fuelcell_ID: "FuelCell1", Date_added: 20200123}, nil},
+		{"supplier without name", Supplier{AssetType: "Supplier", Supplier_ID: "Supplier1", Supplier_name: " "}, []string{"Supplier_name"}},
+		{"valid fuel cell", validFuelcell, nil},
+		{"fuel cell in legacy currency", func() FuelcellData { f := validFuelcell; f.Currency = "Pounds"; return f }(), []string{"Currency"}},
+		{"fuel cell received on an impossible date", func() FuelcellData { f := validFuelcell; f.Date_Received = 20200230; return f }(), []string{"Date_Received"}},
+		{"journey efficiency above 1", JourneyData{AssetType: "Journey", Journey_ID: "Journey1", Car_ID: "Car1", Car_Component_ID: "Component1", Distance: 500, H2_used: 100, Efficiency: 1.5, Journey_date: 20200123}, []string{"Efficiency"}},
+		{"journey with negative values", JourneyData{AssetType: "Journey", Journey_ID: "Journey1", Car_ID: "Car1", Car_Component_ID: "Component1", Odo_start: -1, Distance: -500, H2_used: -100, Efficiency: 0.3, Journey_date: 20200123}, []string{"Odo_start", "Distance", "H2_used"}},
+		{"valid bill", validBill, nil},
+		{"bill in £", func() Bill { b := validBill; b.Currency = "£"; return b }(), []string{"Currency"}},
+		{"bill amount with too many places", func() Bill { b := validBill; b.Amount = "31.505"; return b }(), []string{"Amount"}},
+		{"bill ending before it starts", func() Bill { b := validBill; b.Date_to = 20191231; return b }(), []string{"Date_to"}},
+		{"bill with unknown status", func() Bill { b := validBill; b.Status = "Lost"; return b }(), []string{"Status"}},
+		{"credit note without bill", CreditNote{AssetType: "Credit_Note", Credit_note_ID: "CN1", Supplier_ID: "Supplier1", Currency: "GBP", Amount: "10", Date_issued: 20200201}, []string{"Bill_ID"}},
+		{"tariff without fuel cell or hash", Tariff{AssetType: "Tariff", Tariff_ID: "FuelCell1_tariff_20200201", Effective_from: 20200201}, []string{"Fuelcell_ID", "Rates_hash"}},
+		{"valid rates", TariffRates{AssetType: "Tariff_Rates", Tariff_ID: "FuelCell1_tariff_20200201", Base_rate: "1", Distance_rate: "0.2", Energy_rate: "1", Salt: "3b9e0c51d7a24f86"}, nil},
+		{"negative rate with a short salt", TariffRates{AssetType: "Tariff_Rates", Tariff_ID: "FuelCell1_tariff_20200201", Base_rate: "1", Distance_rate: "-0.2", Energy_rate: "1", Salt: "salt"}, []string{"Distance_rate", "Salt"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.asset.validate()
+			if test.fields == nil {
+				require.NoError(t, err)
+				return
+			}
+			var validationErr *ValidationError
+			require.True(t, errors.As(err, &validationErr), "expected a ValidationError, got %v", err)
+			var fields []string
+			for _, field := range validationErr.Fields {
+				fields = append(fields, field.Field)
+			}
+			require.Equal(t, test.fields, fields)
+		})
+	}
+}
+
+func TestValidationErrorMessage(t *testing.T) {
+	err := Car{AssetType: "Car", Car_ID: "Car1", Date_of_manufacture: "23/01/2020"}.validate()
+	require.EqualError(t, err, `invalid Car Car1: [{"Field":"Date_of_manufacture","Message":"must be a date in the form YYYYMMDD"}]`)
+}
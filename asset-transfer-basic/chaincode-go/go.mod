@@ -6,6 +6,7 @@ require (
 	github.com/hyperledger/fabric-chaincode-go v0.0.0-20230228194215-b84622ba6a7a
 	github.com/hyperledger/fabric-contract-api-go v1.2.1
 	github.com/hyperledger/fabric-protos-go v0.3.0
+	github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/simulator v0.0.0
 	github.com/stretchr/testify v1.8.2
 	google.golang.org/protobuf v1.28.1
 )
@@ -36,3 +37,6 @@ require (
 	gopkg.in/yaml.v2 v2.4.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+// the simulator is a module of its own so other samples can test their chaincode with it too
+replace github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/simulator => ./simulator
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const indexDir = "META-INF/statedb/couchdb/indexes"
+
+// TestSelectorsHaveIndexes fails when the chaincode runs a CouchDB selector whose fields are not
+// covered by one of the indexes packaged under META-INF, which would make the query a full scan.
+func TestSelectorsHaveIndexes(t *testing.T) {
+	indexes := packagedIndexes(t)
+	selectors := chaincodeSelectors(t)
+	require.NotEmpty(t, selectors, "no selectors found in the chaincode source")
+
+	for position, fields := range selectors {
+		require.Contains(t, indexes, strings.Join(fields, ","), "%s: no index in %s covers the selector fields %v", position, indexDir, fields)
+	}
+}
+
+// packagedIndexes returns the fields of every packaged index, sorted and joined with commas
+func packagedIndexes(t *testing.T) map[string]string {
+	files, err := filepath.Glob(filepath.Join(indexDir, "*.json"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files, "no indexes packaged in %s", indexDir)
+
+	indexes := make(map[string]string)
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		require.NoError(t, err)
+		var definition struct {
+			Index struct {
+				Fields []string `json:"fields"`
+			} `json:"index"`
+			Name string `json:"name"`
+		}
+		require.NoError(t, json.Unmarshal(content, &definition), file)
+		require.NotEmpty(t, definition.Index.Fields, file)
+		fields := append([]string(nil), definition.Index.Fields...)
+		sort.Strings(fields)
+		indexes[strings.Join(fields, ",")] = definition.Name
+	}
+	return indexes
+}
+
+// chaincodeSelectors returns the sorted fields of every selector literal in the chaincode source, keyed
+// by where it appears. A query string written out by hand, bypassing the selector type, fails the test.
+func chaincodeSelectors(t *testing.T) map[string][]string {
+	fileSet := token.NewFileSet()
+	packages, err := parser.ParseDir(fileSet, "chaincode", func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	require.NoError(t, err)
+
+	selectors := make(map[string][]string)
+	for _, pkg := range packages {
+		for _, file := range pkg.Files {
+			ast.Inspect(file, func(node ast.Node) bool {
+				switch node := node.(type) {
+				case *ast.BasicLit:
+					position := fileSet.Position(node.Pos()).String()
+					require.NotContains(t, node.Value, `{"selector"`, "%s: build queries with the selector type", position)
+				case *ast.CompositeLit:
+					if ident, ok := node.Type.(*ast.Ident); !ok || ident.Name != "selector" {
+						return true
+					}
+					position := fileSet.Position(node.Pos()).String()
+					var fields []string
+					for _, element := range node.Elts {
+						entry, ok := element.(*ast.KeyValueExpr)
+						require.True(t, ok, "%s: selector entries must be field: value pairs", position)
+						key, ok := entry.Key.(*ast.BasicLit)
+						require.True(t, ok && key.Kind == token.STRING, "%s: selector field names must be literal", position)
+						field, err := strconv.Unquote(key.Value)
+						require.NoError(t, err, position)
+						fields = append(fields, field)
+					}
+					sort.Strings(fields)
+					selectors[position] = fields
+				}
+				return true
+			})
+		}
+	}
+	return selectors
+}
@@ -0,0 +1,41 @@
+// Package example is a chaincode of its own, with a go.mod of its own, whose tests run on the simulator.
+// It shows the simulator imported from outside the asset-transfer-basic chaincode.
+package example
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// CounterContract keeps named counters in the world state
+type CounterContract struct {
+	contractapi.Contract
+}
+
+// Increment adds one to a counter, creating it at one, and returns its new value
+func (c *CounterContract) Increment(ctx contractapi.TransactionContextInterface, name string) (int, error) {
+	count, err := c.Read(ctx, name)
+	if err != nil {
+		return 0, err
+	}
+	count++
+	err = ctx.GetStub().PutState(name, []byte(strconv.Itoa(count)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to put to world state: %v", err)
+	}
+	return count, nil
+}
+
+// Read returns the value of a counter, zero if it was never incremented
+func (c *CounterContract) Read(ctx contractapi.TransactionContextInterface, name string) (int, error) {
+	value, err := ctx.GetStub().GetState(name)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if value == nil {
+		return 0, nil
+	}
+	return strconv.Atoi(string(value))
+}
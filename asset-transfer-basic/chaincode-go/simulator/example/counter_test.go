@@ -0,0 +1,40 @@
+package example_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/simulator"
+	"github.com/hyperledger/fabric-samples/asset-transfer-basic/chaincode-go/simulator/example"
+	"github.com/stretchr/testify/require"
+)
+
+var client = &simulator.Client{ID: "counter", MSPID: "Org1MSP"}
+
+func TestIncrement(t *testing.T) {
+	ledger := simulator.NewLedger(time.Date(2021, time.January, 1, 9, 0, 0, 0, time.UTC))
+	contract := &example.CounterContract{}
+	increment := func(ctx contractapi.TransactionContextInterface) error {
+		_, err := contract.Increment(ctx, "visits")
+		return err
+	}
+
+	require.NoError(t, ledger.Submit(client, increment))
+	require.NoError(t, ledger.Submit(client, increment))
+	require.Equal(t, []byte("2"), ledger.State("visits"))
+
+	// a transaction never reads its own writes, so incrementing twice in one transaction counts once
+	require.NoError(t, ledger.Submit(client, func(ctx contractapi.TransactionContextInterface) error {
+		err := increment(ctx)
+		if err != nil {
+			return err
+		}
+		return increment(ctx)
+	}))
+	require.Equal(t, []byte("3"), ledger.State("visits"))
+
+	// an evaluated transaction is never committed
+	require.NoError(t, ledger.Evaluate(client, increment))
+	require.Equal(t, []byte("3"), ledger.State("visits"))
+}
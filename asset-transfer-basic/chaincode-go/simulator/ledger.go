@@ -0,0 +1,206 @@
+// Package simulator runs chaincode against an in-memory ledger so whole scenarios, such as
+// InitLedger then CreateJourney then GenerateBill, can be tested without a Fabric network.
+//
+// A Ledger holds the committed world state, private data, key history and events of one chaincode.
+// Each transaction gets its own Stub implementing shim.ChaincodeStubInterface. As on a peer, a
+// transaction reads only what was committed before it started, never its own writes, and its writes
+// reach the ledger only when it is committed. Keeping to these rules lets a test catch a contract that
+// relies on reading back a write made earlier in the same transaction.
+//
+// The simulator is a module of its own and knows nothing of the chaincode it runs. It depends only on the
+// shim, its cid package for client identities, the contract API for the transaction contexts of NewContext,
+// Submit and Evaluate, and the Fabric protos, so any chaincode module can require it; see the example module
+// for a chaincode outside asset-transfer-basic that requires it.
+package simulator
+
+import (
+	"crypto/x509"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// ChannelID is the channel every simulated transaction runs on
+const ChannelID = "simulated"
+
+// Ledger is the committed state of one chaincode on a channel
+type Ledger struct {
+	state      map[string][]byte
+	private    map[string]map[string][]byte // collection to key to value
+	history    map[string][]*queryresult.KeyModification
+	validation map[string][]byte // key based endorsement policies, keyed by collection and key
+	events     []*peer.ChaincodeEvent
+	clock      time.Time
+	txCount    int
+}
+
+// NewLedger returns an empty ledger whose first transaction is timestamped at start
+func NewLedger(start time.Time) *Ledger {
+	return &Ledger{
+		state:      make(map[string][]byte),
+		private:    make(map[string]map[string][]byte),
+		history:    make(map[string][]*queryresult.KeyModification),
+		validation: make(map[string][]byte),
+		clock:      start.UTC(),
+	}
+}
+
+// SetTime sets the timestamp of the next transaction. Each transaction after it is a second later.
+func (l *Ledger) SetTime(t time.Time) {
+	l.clock = t.UTC()
+}
+
+// NewStub starts a transaction invoked with the given function name and parameters
+func (l *Ledger) NewStub(args ...string) *Stub {
+	l.txCount++
+	stub := &Stub{
+		ledger:        l,
+		txID:          fmt.Sprintf("tx%d", l.txCount),
+		timestamp:     l.clock,
+		writes:        make(map[string][]byte),
+		privateWrites: make(map[string]map[string][]byte),
+		transient:     make(map[string][]byte),
+	}
+	for _, arg := range args {
+		stub.args = append(stub.args, []byte(arg))
+	}
+	l.clock = l.clock.Add(time.Second)
+	return stub
+}
+
+// NewContext starts a transaction submitted by client, returning a contract API transaction context
+// on it together with its stub
+func (l *Ledger) NewContext(client cid.ClientIdentity) (*contractapi.TransactionContext, *Stub) {
+	stub := l.NewStub()
+	ctx := new(contractapi.TransactionContext)
+	ctx.SetStub(stub)
+	ctx.SetClientIdentity(client)
+	return ctx, stub
+}
+
+// Submit runs fn as a transaction submitted by client, committing its writes if it succeeds and
+// discarding them if it returns an error, as a failed endorsement would
+func (l *Ledger) Submit(client cid.ClientIdentity, fn func(ctx contractapi.TransactionContextInterface) error) error {
+	ctx, stub := l.NewContext(client)
+	err := fn(ctx)
+	if err != nil {
+		return err
+	}
+	return stub.Commit()
+}
+
+// Evaluate runs fn as a transaction evaluated by client, so nothing it writes is committed
+func (l *Ledger) Evaluate(client cid.ClientIdentity, fn func(ctx contractapi.TransactionContextInterface) error) error {
+	ctx, _ := l.NewContext(client)
+	return fn(ctx)
+}
+
+// State returns the committed value of a key, or nil if it does not exist
+func (l *Ledger) State(key string) []byte {
+	return l.state[key]
+}
+
+// PrivateData returns the committed value of a key in a private data collection, or nil if it does not exist
+func (l *Ledger) PrivateData(collection string, key string) []byte {
+	return l.private[collection][key]
+}
+
+// Keys returns every committed key in order, including composite keys
+func (l *Ledger) Keys() []string {
+	return sortedKeys(l.state)
+}
+
+// Events returns the events of every committed transaction in the order they were committed
+func (l *Ledger) Events() []*peer.ChaincodeEvent {
+	return l.events
+}
+
+// commit applies the writes of a transaction and records them in the key history
+func (l *Ledger) commit(s *Stub) {
+	for _, key := range sortedKeys(s.writes) {
+		value := s.writes[key]
+		modification := &queryresult.KeyModification{
+			TxId:      s.txID,
+			Value:     value,
+			Timestamp: timestamppb.New(s.timestamp),
+			IsDelete:  value == nil,
+		}
+		l.history[key] = append(l.history[key], modification)
+		if value == nil {
+			delete(l.state, key)
+		} else {
+			l.state[key] = value
+		}
+	}
+	for collection, writes := range s.privateWrites {
+		if l.private[collection] == nil {
+			l.private[collection] = make(map[string][]byte)
+		}
+		for key, value := range writes {
+			if value == nil {
+				delete(l.private[collection], key)
+			} else {
+				l.private[collection][key] = value
+			}
+		}
+	}
+	if s.event != nil {
+		l.events = append(l.events, s.event)
+	}
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys(values map[string][]byte) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Client is a client identity issued by MSPID holding the given certificate attributes
+type Client struct {
+	ID         string
+	MSPID      string
+	Attributes map[string]string
+}
+
+// GetID returns the ID of the client
+func (c *Client) GetID() (string, error) {
+	return c.ID, nil
+}
+
+// GetMSPID returns the ID of the MSP that issued the client's certificate
+func (c *Client) GetMSPID() (string, error) {
+	return c.MSPID, nil
+}
+
+// GetAttributeValue returns the value of a certificate attribute
+func (c *Client) GetAttributeValue(attrName string) (string, bool, error) {
+	value, found := c.Attributes[attrName]
+	return value, found, nil
+}
+
+// AssertAttributeValue checks that a certificate attribute has the given value
+func (c *Client) AssertAttributeValue(attrName string, attrValue string) error {
+	value, found := c.Attributes[attrName]
+	if !found {
+		return fmt.Errorf("attribute '%s' was not found", attrName)
+	}
+	if value != attrValue {
+		return fmt.Errorf("attribute '%s' equals '%s', not '%s'", attrName, value, attrValue)
+	}
+	return nil
+}
+
+// GetX509Certificate returns nil as simulated clients have no certificate
+func (c *Client) GetX509Certificate() (*x509.Certificate, error) {
+	return nil, nil
+}
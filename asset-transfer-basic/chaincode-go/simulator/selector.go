@@ -0,0 +1,228 @@
+package simulator
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Selector is the selector of a CouchDB rich query. The simulator supports the subset of Mango used
+// by the sample chaincodes:
+//
+//   - fields compared for equality, eg {"AssetType":"Bill","Supplier_ID":"Supplier1"}
+//   - nested fields named with dots, eg {"owner.org":"Org1MSP"}
+//   - the operators $eq, $ne, $gt, $gte, $lt, $lte, $in and $exists on a field
+//   - $and and $or combining selectors
+//
+// As in CouchDB values only match values of the same JSON type, so "1" never matches 1. Queries may
+// also name an index with use_index, which is ignored. Anything else is rejected so a test cannot pass
+// against a query CouchDB would run differently.
+type Selector map[string]json.RawMessage
+
+// ParseQuery reads the selector of a CouchDB rich query
+func ParseQuery(query string) (Selector, error) {
+	var parts map[string]json.RawMessage
+	err := json.Unmarshal([]byte(query), &parts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query %s: %v", query, err)
+	}
+	var selector Selector
+	for name, part := range parts {
+		switch name {
+		case "selector":
+			err = json.Unmarshal(part, &selector)
+			if err != nil {
+				return nil, fmt.Errorf("invalid selector %s: %v", part, err)
+			}
+		case "use_index":
+		default:
+			return nil, fmt.Errorf("query %s uses %s which the simulator does not support", query, name)
+		}
+	}
+	if selector == nil {
+		return nil, fmt.Errorf("query %s has no selector", query)
+	}
+	// check every operator now rather than only those reached when matching
+	_, err = selector.matches(map[string]interface{}{})
+	if err != nil {
+		return nil, err
+	}
+	return selector, nil
+}
+
+// Matches reports whether a value is a JSON document matched by the selector
+func (s Selector) Matches(value []byte) bool {
+	document, err := decode(value)
+	if err != nil {
+		return false
+	}
+	fields, ok := document.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	matched, err := s.matches(fields)
+	return err == nil && matched
+}
+
+func (s Selector) matches(document map[string]interface{}) (bool, error) {
+	matched := true
+	for name, condition := range s {
+		var ok bool
+		var err error
+		switch name {
+		case "$and", "$or":
+			ok, err = combine(name, condition, document)
+		default:
+			if strings.HasPrefix(name, "$") {
+				return false, fmt.Errorf("the simulator does not support the %s operator", name)
+			}
+			value, found := field(document, name)
+			ok, err = satisfies(condition, value, found)
+		}
+		if err != nil {
+			return false, err
+		}
+		matched = matched && ok
+	}
+	return matched, nil
+}
+
+// combine matches a document against an array of selectors joined by $and or $or
+func combine(operator string, condition json.RawMessage, document map[string]interface{}) (bool, error) {
+	var selectors []Selector
+	err := json.Unmarshal(condition, &selectors)
+	if err != nil {
+		return false, fmt.Errorf("%s needs an array of selectors: %v", operator, err)
+	}
+	matched := operator == "$and"
+	for _, selector := range selectors {
+		ok, err := selector.matches(document)
+		if err != nil {
+			return false, err
+		}
+		if operator == "$and" {
+			matched = matched && ok
+		} else {
+			matched = matched || ok
+		}
+	}
+	return matched, nil
+}
+
+// field looks up a field of a document, following dots into nested objects
+func field(document map[string]interface{}, name string) (interface{}, bool) {
+	var value interface{} = document
+	for _, part := range strings.Split(name, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		value, ok = object[part]
+		if !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// satisfies reports whether a field value meets a condition, which is either a value the field must
+// equal or an object of operators
+func satisfies(condition json.RawMessage, value interface{}, found bool) (bool, error) {
+	want, err := decode(condition)
+	if err != nil {
+		return false, err
+	}
+	operators, ok := want.(map[string]interface{})
+	if !ok || !hasOperators(operators) {
+		return found && reflect.DeepEqual(value, want), nil
+	}
+	matched := true
+	for operator, operand := range operators {
+		var ok bool
+		switch operator {
+		case "$eq":
+			ok = found && reflect.DeepEqual(value, operand)
+		case "$ne":
+			ok = found && !reflect.DeepEqual(value, operand)
+		case "$gt", "$gte", "$lt", "$lte":
+			cmp, comparable := compare(value, operand)
+			ok = found && comparable && map[string]bool{
+				"$gt":  cmp > 0,
+				"$gte": cmp >= 0,
+				"$lt":  cmp < 0,
+				"$lte": cmp <= 0,
+			}[operator]
+		case "$in":
+			candidates, isArray := operand.([]interface{})
+			if !isArray {
+				return false, fmt.Errorf("$in needs an array")
+			}
+			for _, candidate := range candidates {
+				ok = ok || (found && reflect.DeepEqual(value, candidate))
+			}
+		case "$exists":
+			exists, isBool := operand.(bool)
+			if !isBool {
+				return false, fmt.Errorf("$exists needs true or false")
+			}
+			ok = found == exists
+		default:
+			return false, fmt.Errorf("the simulator does not support the %s operator", operator)
+		}
+		matched = matched && ok
+	}
+	return matched, nil
+}
+
+func hasOperators(object map[string]interface{}) bool {
+	for name := range object {
+		if strings.HasPrefix(name, "$") {
+			return true
+		}
+	}
+	return false
+}
+
+// compare orders two numbers or two strings, reporting false for values of any other types
+func compare(a interface{}, b interface{}) (int, bool) {
+	switch a := a.(type) {
+	case json.Number:
+		b, ok := b.(json.Number)
+		if !ok {
+			return 0, false
+		}
+		x, errA := a.Float64()
+		y, errB := b.Float64()
+		if errA != nil || errB != nil {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	case string:
+		b, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(a, b), true
+	}
+	return 0, false
+}
+
+// decode reads JSON keeping numbers exact, so large integers compare correctly
+func decode(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	err := decoder.Decode(&value)
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
@@ -0,0 +1,219 @@
+package simulator
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/stretchr/testify/require"
+)
+
+var start = time.Date(2020, time.January, 1, 9, 0, 0, 0, time.UTC)
+
+// keys drains an iterator, returning the keys it produced
+func keys(t *testing.T, iterator shim.StateQueryIteratorInterface) []string {
+	defer iterator.Close()
+	var keys []string
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		require.NoError(t, err)
+		keys = append(keys, result.Key)
+	}
+	return keys
+}
+
+// commit runs a transaction writing the given values
+func commit(t *testing.T, ledger *Ledger, values map[string]string) {
+	stub := ledger.NewStub()
+	for key, value := range values {
+		require.NoError(t, stub.PutState(key, []byte(value)))
+	}
+	require.NoError(t, stub.Commit())
+}
+
+func TestTransactionsReadOnlyCommittedState(t *testing.T) {
+	ledger := NewLedger(start)
+	commit(t, ledger, map[string]string{"asset1": "v1"})
+
+	stub := ledger.NewStub()
+	require.NoError(t, stub.PutState("asset1", []byte("v2")))
+	require.NoError(t, stub.PutState("asset2", []byte("v1")))
+	require.NoError(t, stub.DelState("asset1"))
+	value, err := stub.GetState("asset1")
+	require.NoError(t, err)
+	require.Equal(t, []byte("v1"), value, "a transaction must not read its own writes")
+	value, err = stub.GetState("asset2")
+	require.NoError(t, err)
+	require.Nil(t, value)
+
+	require.NoError(t, stub.Commit())
+	require.Error(t, stub.Commit())
+	require.Nil(t, ledger.State("asset1"))
+	require.Equal(t, []byte("v1"), ledger.State("asset2"))
+}
+
+func TestGetHistoryForKey(t *testing.T) {
+	ledger := NewLedger(start)
+	commit(t, ledger, map[string]string{"asset1": "v1"})
+	commit(t, ledger, map[string]string{"asset1": "v2"})
+	stub := ledger.NewStub()
+	require.NoError(t, stub.DelState("asset1"))
+	require.NoError(t, stub.Commit())
+
+	iterator, err := ledger.NewStub().GetHistoryForKey("asset1")
+	require.NoError(t, err)
+	var values []string
+	var deletes []bool
+	var txIDs []string
+	for iterator.HasNext() {
+		modification, err := iterator.Next()
+		require.NoError(t, err)
+		values = append(values, string(modification.Value))
+		deletes = append(deletes, modification.IsDelete)
+		txIDs = append(txIDs, modification.TxId)
+	}
+	require.Equal(t, []string{"", "v2", "v1"}, values)
+	require.Equal(t, []bool{true, false, false}, deletes)
+	require.Equal(t, []string{"tx3", "tx2", "tx1"}, txIDs)
+}
+
+func TestTransactionTimestamps(t *testing.T) {
+	ledger := NewLedger(start)
+	first, err := ledger.NewStub().GetTxTimestamp()
+	require.NoError(t, err)
+	second, err := ledger.NewStub().GetTxTimestamp()
+	require.NoError(t, err)
+	require.Equal(t, start, first.AsTime())
+	require.Equal(t, start.Add(time.Second), second.AsTime())
+
+	ledger.SetTime(start.AddDate(0, 1, 0))
+	third, err := ledger.NewStub().GetTxTimestamp()
+	require.NoError(t, err)
+	require.Equal(t, start.AddDate(0, 1, 0), third.AsTime())
+}
+
+func TestRangeAndCompositeKeyQueries(t *testing.T) {
+	ledger := NewLedger(start)
+	stub := ledger.NewStub()
+	values := map[string]string{"asset1": "{}", "asset2": "{}", "asset3": "{}"}
+	for _, attributes := range [][]string{{"blue", "asset1"}, {"blue", "asset3"}, {"red", "asset2"}} {
+		key, err := stub.CreateCompositeKey("color~name", attributes)
+		require.NoError(t, err)
+		values[key] = "\x00"
+	}
+	commit(t, ledger, values)
+	stub = ledger.NewStub()
+
+	iterator, err := stub.GetStateByRange("", "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"asset1", "asset2", "asset3"}, keys(t, iterator), "range queries skip composite keys")
+	iterator, err = stub.GetStateByRange("asset2", "asset3")
+	require.NoError(t, err)
+	require.Equal(t, []string{"asset2"}, keys(t, iterator))
+	_, err = stub.GetStateByRange("\x00color~name", "")
+	require.Error(t, err)
+
+	iterator, err = stub.GetStateByPartialCompositeKey("color~name", []string{"blue"})
+	require.NoError(t, err)
+	blue := keys(t, iterator)
+	require.Len(t, blue, 2)
+	objectType, attributes, err := stub.SplitCompositeKey(blue[1])
+	require.NoError(t, err)
+	require.Equal(t, "color~name", objectType)
+	require.Equal(t, []string{"blue", "asset3"}, attributes)
+
+	iterator, metadata, err := stub.GetStateByRangeWithPagination("", "", 2, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"asset1", "asset2"}, keys(t, iterator))
+	require.Equal(t, int32(2), metadata.FetchedRecordsCount)
+	iterator, metadata, err = stub.GetStateByRangeWithPagination("", "", 2, metadata.Bookmark)
+	require.NoError(t, err)
+	require.Equal(t, []string{"asset3"}, keys(t, iterator))
+	require.Empty(t, metadata.Bookmark)
+}
+
+func TestGetQueryResult(t *testing.T) {
+	ledger := NewLedger(start)
+	commit(t, ledger, map[string]string{
+		"bill1":                              `{"AssetType":"Bill","Supplier_ID":"Supplier1","Date_to":20200131,"Status":"Paid"}`,
+		"bill2":                              `{"AssetType":"Bill","Supplier_ID":"Supplier1","Date_to":20200229}`,
+		"bill3":                              `{"AssetType":"Bill","Supplier_ID":"Supplier2","Date_to":20200131,"Status":"Issued"}`,
+		"car1":                               `{"AssetType":"Car","Car_ID":"car1","Owner":{"MSP_ID":"Org1MSP"}}`,
+		"notjson":                            `Bill`,
+		"\x00Car\x00car2\x00":                `{"AssetType":"Car","Car_ID":"car2"}`,
+		"\x00color~name\x00blue\x00car2\x00": "\x00",
+	})
+	tests := []struct {
+		name  string
+		query string
+		keys  []string
+	}{
+		{"equality", `{"selector":{"AssetType":"Bill","Supplier_ID":"Supplier1"}}`, []string{"bill1", "bill2"}},
+		{"values must have the same JSON type", `{"selector":{"Date_to":"20200131"}}`, nil},
+		{"numbers", `{"selector":{"Date_to":20200131}}`, []string{"bill1", "bill3"}},
+		{"comparison", `{"selector":{"AssetType":"Bill","Date_to":{"$gt":20200131}}}`, []string{"bill2"}},
+		{"range", `{"selector":{"Date_to":{"$gte":20200101,"$lte":20200131}}}`, []string{"bill1", "bill3"}},
+		{"missing fields do not match $ne", `{"selector":{"AssetType":"Bill","Status":{"$ne":"Paid"}}}`, []string{"bill3"}},
+		{"exists", `{"selector":{"AssetType":"Bill","Status":{"$exists":false}}}`, []string{"bill2"}},
+		{"in", `{"selector":{"Supplier_ID":{"$in":["Supplier2","Supplier3"]}}}`, []string{"bill3"}},
+		{"or", `{"selector":{"$or":[{"Status":"Paid"},{"AssetType":"Car"}]}}`, []string{"\x00Car\x00car2\x00", "bill1", "car1"}},
+		{"nested field", `{"selector":{"Owner.MSP_ID":"Org1MSP"}}`, []string{"car1"}},
+		{"documents under composite keys", `{"selector":{"Car_ID":"car2"}}`, []string{"\x00Car\x00car2\x00"}},
+		{"index named", `{"selector":{"AssetType":"Car"},"use_index":["_design/indexCarDoc","indexCar"]}`, []string{"\x00Car\x00car2\x00", "car1"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			iterator, err := ledger.NewStub().GetQueryResult(test.query)
+			require.NoError(t, err)
+			require.Equal(t, test.keys, keys(t, iterator))
+		})
+	}
+
+	for _, query := range []string{
+		`{"selector":{"Date_to":{"$regex":"^2020"}}}`,
+		`{"selector":{"AssetType":"Bill"},"sort":[{"Date_to":"asc"}]}`,
+		`{"selector":{"$nor":[{"AssetType":"Bill"}]}}`,
+		`{"AssetType":"Bill"}`,
+		`not a query`,
+	} {
+		_, err := ledger.NewStub().GetQueryResult(query)
+		require.Error(t, err, query)
+	}
+
+	iterator, metadata, err := ledger.NewStub().GetQueryResultWithPagination(`{"selector":{"AssetType":"Bill"}}`, 2, "")
+	require.NoError(t, err)
+	require.Equal(t, []string{"bill1", "bill2"}, keys(t, iterator))
+	iterator, _, err = ledger.NewStub().GetQueryResultWithPagination(`{"selector":{"AssetType":"Bill"}}`, 2, metadata.Bookmark)
+	require.NoError(t, err)
+	require.Equal(t, []string{"bill3"}, keys(t, iterator))
+}
+
+func TestPrivateDataAndEvents(t *testing.T) {
+	ledger := NewLedger(start)
+	stub := ledger.NewStub()
+	require.NoError(t, stub.PutPrivateData("collection1", "asset1", []byte("secret")))
+	require.NoError(t, stub.SetEvent("First", []byte("1")))
+	require.NoError(t, stub.SetEvent("Second", []byte("2")))
+	value, err := stub.GetPrivateData("collection1", "asset1")
+	require.NoError(t, err)
+	require.Nil(t, value)
+	require.NoError(t, stub.Commit())
+
+	require.Equal(t, []byte("secret"), ledger.PrivateData("collection1", "asset1"))
+	hash, err := ledger.NewStub().GetPrivateDataHash("collection1", "asset1")
+	require.NoError(t, err)
+	require.Len(t, hash, 32)
+	require.Len(t, ledger.Events(), 1, "only the last event of a transaction is emitted")
+	require.Equal(t, "Second", ledger.Events()[0].EventName)
+}
+
+func TestClient(t *testing.T) {
+	client := &Client{ID: "user1", MSPID: "Org1MSP", Attributes: map[string]string{"role": "admin"}}
+	ctx, _ := NewLedger(start).NewContext(client)
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	require.NoError(t, err)
+	require.Equal(t, "Org1MSP", mspID)
+	require.NoError(t, ctx.GetClientIdentity().AssertAttributeValue("role", "admin"))
+	require.Error(t, ctx.GetClientIdentity().AssertAttributeValue("role", "user"))
+	require.Error(t, ctx.GetClientIdentity().AssertAttributeValue("team", "admin"))
+}
@@ -0,0 +1,470 @@
+package simulator
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	compositeKeyNamespace = "\x00"
+	emptyKeySubstitute    = "\x01" // start of the simple key namespace, used for an empty start key
+	maxUnicodeRune        = string(utf8.MaxRune)
+)
+
+// Stub is one transaction on a Ledger. Reads see the ledger as it was committed, and writes are held
+// until Commit.
+type Stub struct {
+	ledger        *Ledger
+	txID          string
+	timestamp     time.Time
+	args          [][]byte
+	transient     map[string][]byte
+	writes        map[string][]byte            // nil values are deletes
+	privateWrites map[string]map[string][]byte // collection to key to value, nil values are deletes
+	event         *peer.ChaincodeEvent
+	committed     bool
+}
+
+var _ shim.ChaincodeStubInterface = (*Stub)(nil)
+
+// Commit applies the transaction's writes to the ledger. A transaction can only be committed once.
+func (s *Stub) Commit() error {
+	if s.committed {
+		return fmt.Errorf("transaction %s has already been committed", s.txID)
+	}
+	s.committed = true
+	s.ledger.commit(s)
+	return nil
+}
+
+// SetTransient sets the transient data passed with the transaction proposal
+func (s *Stub) SetTransient(transient map[string][]byte) {
+	s.transient = transient
+}
+
+// Writes returns the value the transaction has written to each key, with nil for a deleted key
+func (s *Stub) Writes() map[string][]byte {
+	return s.writes
+}
+
+// Event returns the event set by the transaction, or nil if it set none
+func (s *Stub) Event() *peer.ChaincodeEvent {
+	return s.event
+}
+
+// GetArgs returns the arguments the transaction was invoked with
+func (s *Stub) GetArgs() [][]byte {
+	return s.args
+}
+
+// GetStringArgs returns the arguments the transaction was invoked with as strings
+func (s *Stub) GetStringArgs() []string {
+	args := make([]string, len(s.args))
+	for i, arg := range s.args {
+		args[i] = string(arg)
+	}
+	return args
+}
+
+// GetFunctionAndParameters returns the first argument as the function name and the rest as its parameters
+func (s *Stub) GetFunctionAndParameters() (string, []string) {
+	args := s.GetStringArgs()
+	if len(args) == 0 {
+		return "", []string{}
+	}
+	return args[0], args[1:]
+}
+
+// GetArgsSlice returns the arguments the transaction was invoked with concatenated
+func (s *Stub) GetArgsSlice() ([]byte, error) {
+	var slice []byte
+	for _, arg := range s.args {
+		slice = append(slice, arg...)
+	}
+	return slice, nil
+}
+
+// GetTxID returns the ID of the transaction
+func (s *Stub) GetTxID() string {
+	return s.txID
+}
+
+// GetChannelID returns the simulated channel
+func (s *Stub) GetChannelID() string {
+	return ChannelID
+}
+
+// InvokeChaincode fails as calls to other chaincodes are not simulated
+func (s *Stub) InvokeChaincode(chaincodeName string, args [][]byte, channel string) peer.Response {
+	return shim.Error(fmt.Sprintf("cannot invoke chaincode %s: chaincode to chaincode calls are not simulated", chaincodeName))
+}
+
+// GetState returns the committed value of a key
+func (s *Stub) GetState(key string) ([]byte, error) {
+	return s.ledger.state[key], nil
+}
+
+// PutState writes a value to a key when the transaction is committed
+func (s *Stub) PutState(key string, value []byte) error {
+	if key == "" {
+		return fmt.Errorf("key must not be an empty string")
+	}
+	if !utf8.ValidString(key) {
+		return fmt.Errorf("key %x is not a valid utf8 string", key)
+	}
+	if value == nil {
+		value = []byte{} // nil values are deletes
+	}
+	s.writes[key] = value
+	return nil
+}
+
+// DelState deletes a key when the transaction is committed
+func (s *Stub) DelState(key string) error {
+	s.writes[key] = nil
+	return nil
+}
+
+// SetStateValidationParameter sets the key level endorsement policy of a key
+func (s *Stub) SetStateValidationParameter(key string, ep []byte) error {
+	return s.SetPrivateDataValidationParameter("", key, ep)
+}
+
+// GetStateValidationParameter returns the key level endorsement policy of a key
+func (s *Stub) GetStateValidationParameter(key string) ([]byte, error) {
+	return s.GetPrivateDataValidationParameter("", key)
+}
+
+// GetStateByRange iterates over the simple keys from startKey up to but excluding endKey. Empty keys
+// leave that end of the range open.
+func (s *Stub) GetStateByRange(startKey string, endKey string) (shim.StateQueryIteratorInterface, error) {
+	iterator, _, err := s.GetStateByRangeWithPagination(startKey, endKey, 0, "")
+	return iterator, err
+}
+
+// GetStateByRangeWithPagination returns up to pageSize simple keys of a range, starting at the key named
+// by the bookmark. A page size of 0 returns every key.
+func (s *Stub) GetStateByRangeWithPagination(startKey string, endKey string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	if startKey == "" {
+		startKey = emptyKeySubstitute
+	}
+	err := validateSimpleKeys(startKey, endKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.rangeQuery(s.ledger.state, startKey, endKey, pageSize, bookmark)
+}
+
+// GetStateByPartialCompositeKey iterates over the composite keys of objectType beginning with attributes
+func (s *Stub) GetStateByPartialCompositeKey(objectType string, attributes []string) (shim.StateQueryIteratorInterface, error) {
+	iterator, _, err := s.GetStateByPartialCompositeKeyWithPagination(objectType, attributes, 0, "")
+	return iterator, err
+}
+
+// GetStateByPartialCompositeKeyWithPagination returns up to pageSize composite keys of objectType
+// beginning with attributes, starting at the key named by the bookmark
+func (s *Stub) GetStateByPartialCompositeKeyWithPagination(objectType string, attributes []string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	startKey, err := shim.CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.rangeQuery(s.ledger.state, startKey, startKey+maxUnicodeRune, pageSize, bookmark)
+}
+
+// CreateCompositeKey combines an object type and attributes into a composite key
+func (s *Stub) CreateCompositeKey(objectType string, attributes []string) (string, error) {
+	return shim.CreateCompositeKey(objectType, attributes)
+}
+
+// SplitCompositeKey splits a composite key into its object type and attributes
+func (s *Stub) SplitCompositeKey(compositeKey string) (string, []string, error) {
+	if !strings.HasPrefix(compositeKey, compositeKeyNamespace) || !strings.HasSuffix(compositeKey, "\x00") || len(compositeKey) < 2 {
+		return "", nil, fmt.Errorf("%q is not a composite key", compositeKey)
+	}
+	components := strings.Split(compositeKey[1:len(compositeKey)-1], "\x00")
+	return components[0], components[1:], nil
+}
+
+// GetQueryResult runs a CouchDB rich query against the committed state, see Selector for what is supported
+func (s *Stub) GetQueryResult(query string) (shim.StateQueryIteratorInterface, error) {
+	iterator, _, err := s.GetQueryResultWithPagination(query, 0, "")
+	return iterator, err
+}
+
+// GetQueryResultWithPagination returns up to pageSize results of a CouchDB rich query, starting at the
+// result named by the bookmark
+func (s *Stub) GetQueryResultWithPagination(query string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	return s.richQuery(s.ledger.state, query, pageSize, bookmark)
+}
+
+// GetHistoryForKey iterates over every committed write to a key, most recent first
+func (s *Stub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	modifications := s.ledger.history[key]
+	newestFirst := make([]*queryresult.KeyModification, len(modifications))
+	for i, modification := range modifications {
+		newestFirst[len(modifications)-1-i] = modification
+	}
+	return &historyIterator{modifications: newestFirst}, nil
+}
+
+// GetPrivateData returns the committed value of a key in a private data collection
+func (s *Stub) GetPrivateData(collection string, key string) ([]byte, error) {
+	if collection == "" {
+		return nil, fmt.Errorf("collection must not be an empty string")
+	}
+	return s.ledger.private[collection][key], nil
+}
+
+// GetPrivateDataHash returns the SHA-256 hash of the committed value of a key in a private data collection
+func (s *Stub) GetPrivateDataHash(collection string, key string) ([]byte, error) {
+	value, err := s.GetPrivateData(collection, key)
+	if err != nil || value == nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(value)
+	return hash[:], nil
+}
+
+// PutPrivateData writes a value to a key in a private data collection when the transaction is committed
+func (s *Stub) PutPrivateData(collection string, key string, value []byte) error {
+	if collection == "" {
+		return fmt.Errorf("collection must not be an empty string")
+	}
+	if key == "" {
+		return fmt.Errorf("key must not be an empty string")
+	}
+	if value == nil {
+		value = []byte{}
+	}
+	s.privateWrite(collection, key, value)
+	return nil
+}
+
+// DelPrivateData deletes a key in a private data collection when the transaction is committed
+func (s *Stub) DelPrivateData(collection string, key string) error {
+	if collection == "" {
+		return fmt.Errorf("collection must not be an empty string")
+	}
+	s.privateWrite(collection, key, nil)
+	return nil
+}
+
+// PurgePrivateData deletes a key in a private data collection when the transaction is committed. The
+// simulator keeps no private data history, so this is the same as DelPrivateData.
+func (s *Stub) PurgePrivateData(collection string, key string) error {
+	return s.DelPrivateData(collection, key)
+}
+
+func (s *Stub) privateWrite(collection string, key string, value []byte) {
+	if s.privateWrites[collection] == nil {
+		s.privateWrites[collection] = make(map[string][]byte)
+	}
+	s.privateWrites[collection][key] = value
+}
+
+// SetPrivateDataValidationParameter sets the key level endorsement policy of a key in a private data
+// collection. Policies are recorded but not enforced.
+func (s *Stub) SetPrivateDataValidationParameter(collection string, key string, ep []byte) error {
+	s.ledger.validation[collection+"\x00"+key] = ep
+	return nil
+}
+
+// GetPrivateDataValidationParameter returns the key level endorsement policy of a key in a private data collection
+func (s *Stub) GetPrivateDataValidationParameter(collection string, key string) ([]byte, error) {
+	return s.ledger.validation[collection+"\x00"+key], nil
+}
+
+// GetPrivateDataByRange iterates over the keys of a private data collection from startKey up to but excluding endKey
+func (s *Stub) GetPrivateDataByRange(collection string, startKey string, endKey string) (shim.StateQueryIteratorInterface, error) {
+	if collection == "" {
+		return nil, fmt.Errorf("collection must not be an empty string")
+	}
+	if startKey == "" {
+		startKey = emptyKeySubstitute
+	}
+	err := validateSimpleKeys(startKey, endKey)
+	if err != nil {
+		return nil, err
+	}
+	iterator, _, err := s.rangeQuery(s.ledger.private[collection], startKey, endKey, 0, "")
+	return iterator, err
+}
+
+// GetPrivateDataByPartialCompositeKey iterates over the composite keys of objectType beginning with
+// attributes in a private data collection
+func (s *Stub) GetPrivateDataByPartialCompositeKey(collection string, objectType string, attributes []string) (shim.StateQueryIteratorInterface, error) {
+	if collection == "" {
+		return nil, fmt.Errorf("collection must not be an empty string")
+	}
+	startKey, err := shim.CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		return nil, err
+	}
+	iterator, _, err := s.rangeQuery(s.ledger.private[collection], startKey, startKey+maxUnicodeRune, 0, "")
+	return iterator, err
+}
+
+// GetPrivateDataQueryResult runs a CouchDB rich query against a private data collection
+func (s *Stub) GetPrivateDataQueryResult(collection string, query string) (shim.StateQueryIteratorInterface, error) {
+	if collection == "" {
+		return nil, fmt.Errorf("collection must not be an empty string")
+	}
+	iterator, _, err := s.richQuery(s.ledger.private[collection], query, 0, "")
+	return iterator, err
+}
+
+// GetCreator returns nil as simulated clients have no serialized identity; use the contract API
+// client identity instead
+func (s *Stub) GetCreator() ([]byte, error) {
+	return nil, nil
+}
+
+// GetTransient returns the transient data passed with the transaction proposal
+func (s *Stub) GetTransient() (map[string][]byte, error) {
+	return s.transient, nil
+}
+
+// GetBinding returns nil as simulated transactions have no proposal
+func (s *Stub) GetBinding() ([]byte, error) {
+	return nil, nil
+}
+
+// GetDecorations returns nil as simulated transactions have no proposal
+func (s *Stub) GetDecorations() map[string][]byte {
+	return nil
+}
+
+// GetSignedProposal returns nil as simulated transactions have no proposal
+func (s *Stub) GetSignedProposal() (*peer.SignedProposal, error) {
+	return nil, nil
+}
+
+// GetTxTimestamp returns the time the transaction was started
+func (s *Stub) GetTxTimestamp() (*timestamppb.Timestamp, error) {
+	return timestamppb.New(s.timestamp), nil
+}
+
+// SetEvent sets the event emitted when the transaction is committed. As on a peer, only the last
+// event set by a transaction is kept.
+func (s *Stub) SetEvent(name string, payload []byte) error {
+	if name == "" {
+		return fmt.Errorf("event name can not be empty string")
+	}
+	s.event = &peer.ChaincodeEvent{TxId: s.txID, EventName: name, Payload: payload}
+	return nil
+}
+
+// rangeQuery returns up to pageSize entries of values with keys from startKey up to but excluding
+// endKey, starting at the bookmarked key. The bookmark returned names the first key of the next page.
+func (s *Stub) rangeQuery(values map[string][]byte, startKey string, endKey string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	if bookmark != "" && bookmark > startKey {
+		startKey = bookmark
+	}
+	var results []*queryresult.KV
+	for _, key := range sortedKeys(values) {
+		if key < startKey || (endKey != "" && key >= endKey) {
+			continue
+		}
+		results = append(results, &queryresult.KV{Namespace: ChannelID, Key: key, Value: values[key]})
+	}
+	return page(results, pageSize, func(next *queryresult.KV) string { return next.Key })
+}
+
+// richQuery returns up to pageSize entries of values matching a CouchDB query in key order, starting
+// at the bookmarked key
+func (s *Stub) richQuery(values map[string][]byte, query string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	selector, err := ParseQuery(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	var results []*queryresult.KV
+	for _, key := range sortedKeys(values) {
+		if key < bookmark {
+			continue
+		}
+		if selector.Matches(values[key]) {
+			results = append(results, &queryresult.KV{Namespace: ChannelID, Key: key, Value: values[key]})
+		}
+	}
+	return page(results, pageSize, func(next *queryresult.KV) string { return next.Key })
+}
+
+// page cuts results down to pageSize, returning a bookmark naming the first result left out
+func page(results []*queryresult.KV, pageSize int32, bookmark func(next *queryresult.KV) string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	if pageSize < 0 {
+		return nil, nil, fmt.Errorf("page size %d must not be negative", pageSize)
+	}
+	metadata := &peer.QueryResponseMetadata{}
+	if pageSize > 0 && len(results) > int(pageSize) {
+		metadata.Bookmark = bookmark(results[pageSize])
+		results = results[:pageSize]
+	}
+	metadata.FetchedRecordsCount = int32(len(results))
+	return &stateIterator{results: results}, metadata, nil
+}
+
+// validateSimpleKeys checks the keys are not in the composite key namespace
+func validateSimpleKeys(keys ...string) error {
+	for _, key := range keys {
+		if strings.HasPrefix(key, compositeKeyNamespace) {
+			return fmt.Errorf("first character of the key [%s] contains a null character which is not allowed", key)
+		}
+	}
+	return nil
+}
+
+// stateIterator iterates over a fixed set of query results
+type stateIterator struct {
+	results []*queryresult.KV
+	closed  bool
+}
+
+func (i *stateIterator) HasNext() bool {
+	return !i.closed && len(i.results) > 0
+}
+
+func (i *stateIterator) Next() (*queryresult.KV, error) {
+	if !i.HasNext() {
+		return nil, fmt.Errorf("no more results")
+	}
+	next := i.results[0]
+	i.results = i.results[1:]
+	return next, nil
+}
+
+func (i *stateIterator) Close() error {
+	i.closed = true
+	return nil
+}
+
+// historyIterator iterates over the history of a key
+type historyIterator struct {
+	modifications []*queryresult.KeyModification
+	closed        bool
+}
+
+func (i *historyIterator) HasNext() bool {
+	return !i.closed && len(i.modifications) > 0
+}
+
+func (i *historyIterator) Next() (*queryresult.KeyModification, error) {
+	if !i.HasNext() {
+		return nil, fmt.Errorf("no more results")
+	}
+	next := i.modifications[0]
+	i.modifications = i.modifications[1:]
+	return next, nil
+}
+
+func (i *historyIterator) Close() error {
+	i.closed = true
+	return nil
+}